@@ -8,9 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"golang.org/x/exp/slices"
@@ -34,13 +35,18 @@ type aks struct {
 	location                            string
 	principalId                         string
 	clientId                            string
-	options                             map[string]struct{}
+	// oidcIssuerUrl is only set when the cluster was created with WorkloadIdentityOpt
+	oidcIssuerUrl string
+	// options are the names of the McOpts the cluster was created with and an IpFamilyOption for each of its ip families
+	options map[string]struct{}
 }
 
 // McOpt specifies what kind of managed cluster to create
 type McOpt struct {
 	Name string
 	fn   func(mc *armcontainerservice.ManagedCluster) error
+	// Vnet is the layout the cluster's vnet needs with this option, nil when DefaultVnetLayout works
+	Vnet *VnetLayout
 }
 
 // PrivateClusterOpt specifies that the cluster should be private
@@ -60,8 +66,142 @@ var PrivateClusterOpt = McOpt{
 	},
 }
 
+// WorkloadIdentityOpt enables the OIDC issuer and the workload identity webhook so pods can authenticate
+// as a user assigned identity through a federated service account token
+var WorkloadIdentityOpt = McOpt{
+	Name: "workload identity",
+	fn: func(mc *armcontainerservice.ManagedCluster) error {
+		if mc.Properties == nil {
+			mc.Properties = &armcontainerservice.ManagedClusterProperties{}
+		}
+
+		if mc.Properties.OidcIssuerProfile == nil {
+			mc.Properties.OidcIssuerProfile = &armcontainerservice.ManagedClusterOIDCIssuerProfile{}
+		}
+		mc.Properties.OidcIssuerProfile.Enabled = to.Ptr(true)
+
+		if mc.Properties.SecurityProfile == nil {
+			mc.Properties.SecurityProfile = &armcontainerservice.ManagedClusterSecurityProfile{}
+		}
+		mc.Properties.SecurityProfile.WorkloadIdentity = &armcontainerservice.ManagedClusterSecurityProfileWorkloadIdentity{
+			Enabled: to.Ptr(true),
+		}
+		return nil
+	},
+}
+
+// AzureCniOpt gives pods ips from the node subnet instead of the kubenet pod range. Azure CNI without overlay
+// doesn't support dual stack so the cluster is ipv4 only, the same as with IPv4Opt
+var AzureCniOpt = McOpt{
+	Name: "azure cni",
+	fn: func(mc *armcontainerservice.ManagedCluster) error {
+		setNetworkProfile(mc, func(np *armcontainerservice.NetworkProfile) {
+			np.NetworkPlugin = to.Ptr(armcontainerservice.NetworkPluginAzure)
+			np.IPFamilies = []*armcontainerservice.IPFamily{to.Ptr(armcontainerservice.IPFamilyIPv4)}
+		})
+		return nil
+	},
+	Vnet: &AzureCniVnetLayout,
+}
+
+// AzureCniOverlayOpt gives pods ips from a private range outside the vnet, the vnet only holds node ips
+var AzureCniOverlayOpt = McOpt{
+	Name: "azure cni overlay",
+	fn: func(mc *armcontainerservice.ManagedCluster) error {
+		setNetworkProfile(mc, func(np *armcontainerservice.NetworkProfile) {
+			np.NetworkPlugin = to.Ptr(armcontainerservice.NetworkPluginAzure)
+			np.NetworkPluginMode = to.Ptr(armcontainerservice.NetworkPluginModeOverlay)
+		})
+		return nil
+	},
+}
+
+// CiliumOpt is Azure CNI overlay with the Cilium dataplane, which replaces kube-proxy and enforces network policy
+var CiliumOpt = McOpt{
+	Name: "cilium dataplane",
+	fn: func(mc *armcontainerservice.ManagedCluster) error {
+		setNetworkProfile(mc, func(np *armcontainerservice.NetworkProfile) {
+			np.NetworkPlugin = to.Ptr(armcontainerservice.NetworkPluginAzure)
+			np.NetworkPluginMode = to.Ptr(armcontainerservice.NetworkPluginModeOverlay)
+			np.NetworkDataplane = to.Ptr(armcontainerservice.NetworkDataplaneCilium)
+			np.NetworkPolicy = to.Ptr(armcontainerservice.NetworkPolicyCilium)
+		})
+		return nil
+	},
+}
+
+// IPv4Opt creates an ipv4 only cluster, services can't get ipv6 addresses
+var IPv4Opt = ipFamilyOpt("ipv4", armcontainerservice.IPFamilyIPv4)
+
+// IPv6PrimaryOpt creates a dual stack cluster where ipv6 is the primary family, services without an ip family get ipv6 addresses
+var IPv6PrimaryOpt = ipFamilyOpt("ipv6 primary", armcontainerservice.IPFamilyIPv6, armcontainerservice.IPFamilyIPv4)
+
+// DualStackOpt creates a dual stack cluster where ipv4 is the primary family, clusters are created this way by default
+var DualStackOpt = ipFamilyOpt("dual stack", armcontainerservice.IPFamilyIPv4, armcontainerservice.IPFamilyIPv6)
+
+// Returns an option creating the cluster with families, the first is the primary family
+func ipFamilyOpt(name string, families ...armcontainerservice.IPFamily) McOpt {
+	return McOpt{
+		Name: name,
+		fn: func(mc *armcontainerservice.ManagedCluster) error {
+			setNetworkProfile(mc, func(np *armcontainerservice.NetworkProfile) {
+				np.IPFamilies = make([]*armcontainerservice.IPFamily, len(families))
+				for i, family := range families {
+					np.IPFamilies[i] = to.Ptr(family)
+				}
+			})
+			return nil
+		},
+	}
+}
+
+// kubernetesVersionOptPrefix prefixes the name of options created by KubernetesVersionOpt
+const kubernetesVersionOptPrefix = "kubernetes version "
+
+// KubernetesVersionOpt creates the cluster on version, a major.minor or major.minor.patch version AKS supports in the
+// cluster's location. Clusters get the location's default version without it
+func KubernetesVersionOpt(version string) McOpt {
+	return McOpt{
+		Name: kubernetesVersionOptPrefix + version,
+		fn: func(mc *armcontainerservice.ManagedCluster) error {
+			if version == "" {
+				return fmt.Errorf("kubernetes version is empty")
+			}
+
+			if mc.Properties == nil {
+				mc.Properties = &armcontainerservice.ManagedClusterProperties{}
+			}
+			mc.Properties.KubernetesVersion = to.Ptr(version)
+			return nil
+		},
+	}
+}
+
+// Returns whether the option was created by KubernetesVersionOpt
+func (o McOpt) IsKubernetesVersion() bool {
+	return strings.HasPrefix(o.Name, kubernetesVersionOptPrefix)
+}
+
+// IpFamilyOption is the option recorded for every ip family a cluster was created with, whichever McOpt chose them
+func IpFamilyOption(family armcontainerservice.IPFamily) string {
+	return "ip family " + string(family)
+}
+
+// Calls fn with the network profile of mc, creating the profile when mc has none
+func setNetworkProfile(mc *armcontainerservice.ManagedCluster, fn func(np *armcontainerservice.NetworkProfile)) {
+	if mc.Properties == nil {
+		mc.Properties = &armcontainerservice.ManagedClusterProperties{}
+	}
+
+	if mc.Properties.NetworkProfile == nil {
+		mc.Properties.NetworkProfile = &armcontainerservice.NetworkProfile{}
+	}
+
+	fn(mc.Properties.NetworkProfile)
+}
+
 // Retrieves objects from infastructure file to create aks instance
-func LoadAks(id azure.Resource, dnsServiceIp, location, principalId, clientId string, options map[string]struct{}) *aks {
+func LoadAks(id azure.Resource, dnsServiceIp, location, principalId, clientId, oidcIssuerUrl string, options map[string]struct{}) *aks {
 	return &aks{
 		name:           id.ResourceName,
 		subscriptionId: id.SubscriptionID,
@@ -71,6 +211,7 @@ func LoadAks(id azure.Resource, dnsServiceIp, location, principalId, clientId st
 		dnsServiceIp:   dnsServiceIp,
 		location:       location,
 		principalId:    principalId,
+		oidcIssuerUrl:  oidcIssuerUrl,
 		options:        options,
 	}
 }
@@ -170,6 +311,20 @@ func NewAks(ctx context.Context, subscriptionId, resourceGroup, name, location s
 		return nil, fmt.Errorf("kubelet identity client id is nil")
 	}
 
+	var oidcIssuerUrl string
+	if issuer := result.Properties.OidcIssuerProfile; issuer != nil && issuer.IssuerURL != nil {
+		oidcIssuerUrl = *issuer.IssuerURL
+	}
+
+	// the families are read back from the created cluster since AKS decides them when no option did
+	if np := result.Properties.NetworkProfile; np != nil {
+		for _, family := range np.IPFamilies {
+			if family != nil {
+				options[IpFamilyOption(*family)] = struct{}{}
+			}
+		}
+	}
+
 	return &aks{
 		name:           *result.ManagedCluster.Name,
 		subscriptionId: subscriptionId,
@@ -179,6 +334,7 @@ func NewAks(ctx context.Context, subscriptionId, resourceGroup, name, location s
 		location:       location,
 		principalId:    *identity.ObjectID,
 		clientId:       *identity.ClientID,
+		oidcIssuerUrl:  oidcIssuerUrl,
 		options:        options,
 	}, nil
 }
@@ -216,6 +372,50 @@ func (a *aks) Deploy(ctx context.Context, objs []client.Object) error {
 	return nil
 }
 
+// Returns whether every one of objs exists on the cluster, only their kinds, namespaces and names are compared
+func (a *aks) HasObjects(ctx context.Context, objs []client.Object) (bool, error) {
+	lgr := logger.FromContext(ctx).With("name", a.name, "resourceGroup", a.resourceGroup)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to look up resources")
+	defer lgr.Info("finished looking up resources")
+
+	zip, err := zipManifests(objs)
+	if err != nil {
+		return false, fmt.Errorf("zipping manifests: %w", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(zip)
+
+	err = a.runCommand(ctx, armcontainerservice.RunCommandRequest{
+		Command: to.Ptr("kubectl get -f manifests/"),
+		Context: &encoded,
+	}, runCommandOpts{})
+	if errors.Is(err, nonZeroExitCode) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("running kubectl get: %w", err)
+	}
+
+	return true, nil
+}
+
+// Applies the manifests hosted at url to the cluster and waits for any CRDs they define to be established.
+// Used for third party installs that are too large to build as objects, server side apply avoids the annotation size limit on large CRDs
+func (a *aks) ApplyUrl(ctx context.Context, url string) error {
+	lgr := logger.FromContext(ctx).With("name", a.name, "resourceGroup", a.resourceGroup, "url", url)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to apply manifests from url")
+	defer lgr.Info("finished applying manifests from url")
+
+	if err := a.runCommand(ctx, armcontainerservice.RunCommandRequest{
+		Command: to.Ptr(fmt.Sprintf("kubectl apply --server-side -f %s && kubectl wait --for=condition=Established crd --all --timeout=2m", url)),
+	}, runCommandOpts{}); err != nil {
+		return fmt.Errorf("running kubectl apply for %s: %w", url, err)
+	}
+
+	return nil
+}
+
 // zipManifests wraps manifests into base64 zip file.
 // this is specified by the AKS ARM API.
 // https://github.com/FumingZhang/azure-cli/blob/aefcf3948ed4207bfcf5d53064e5dac8ea8f19ca/src/azure-cli/azure/cli/command_modules/acs/custom.py#L2750
@@ -484,6 +684,10 @@ func (a *aks) GetClientId() string {
 	return a.clientId
 }
 
+func (a *aks) GetOidcIssuerUrl() string {
+	return a.oidcIssuerUrl
+}
+
 func (a *aks) GetOptions() map[string]struct{} {
 	return a.options
 }
@@ -2,8 +2,13 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
 	"github.com/google/uuid"
@@ -34,11 +39,38 @@ var (
 		Name: "Network Contributor",
 		Id:   "/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/b34d265f-36f7-4a0d-a4d4-e158ca92e90f",
 	}
+	// ReaderRole lets external dns list zones and records but not write them, only the rbac tests assign it
+	ReaderRole = Role{
+		Name: "Reader",
+		Id:   "/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/acdd72d7-3057-4111-a6b2-b9a28bf8e0a4",
+	}
+)
+
+const (
+	// principalReplicationTimeout is how long a new principal can take to reach the replica Azure checks role assignments against
+	principalReplicationTimeout = 5 * time.Minute
+	// roleAssignmentReads is how many reads in a row must find a new assignment before it's treated as effective, a
+	// single read can hit a replica that has it while the one authorizing external dns doesn't yet
+	roleAssignmentReads   = 3
+	roleAssignmentTimeout = 5 * time.Minute
 )
 
-type roleAssignment struct{}
+type roleAssignment struct {
+	id, principalId, roleName string
+}
+
+// Called when loading provisioned infrastructure from .json file
+func LoadRoleAssignment(id, principalId, roleName string) *roleAssignment {
+	return &roleAssignment{
+		id:          id,
+		principalId: principalId,
+		roleName:    roleName,
+	}
+}
 
-// Returns a new role assignment to assign to a provisoined infra using the provisioned cluster's principal id
+// Returns a new role assignment to assign to a provisoined infra using the provisioned cluster's principal id.
+// The assignment is named after its scope, principal and role so creating it again returns the existing assignment.
+// Retries while the principal hasn't replicated and returns once the assignment reads back consistently
 func NewRoleAssignment(ctx context.Context, subscriptionId, scope, principalId string, role Role) (*roleAssignment, error) {
 	lgr := logger.FromContext(ctx).With("role", role.Name, "subscriptionId", subscriptionId, "scope", scope, "principalId", principalId)
 	ctx = logger.WithContext(ctx, lgr)
@@ -55,15 +87,152 @@ func NewRoleAssignment(ctx context.Context, subscriptionId, scope, principalId s
 		return nil, fmt.Errorf("creating client: %w", err)
 	}
 
-	_, err = client.Create(ctx, scope, uuid.New().String(), armauthorization.RoleAssignmentCreateParameters{
-		Properties: &armauthorization.RoleAssignmentProperties{
-			RoleDefinitionID: to.Ptr(fmt.Sprintf(role.Id, subscriptionId)),
-			PrincipalID:      to.Ptr(principalId),
-		},
-	}, nil)
-	if err != nil {
+	roleDefinitionId := fmt.Sprintf(role.Id, subscriptionId)
+	name := roleAssignmentName(scope, principalId, roleDefinitionId)
+
+	var id string
+	deadline := time.Now().Add(principalReplicationTimeout)
+	for {
+		resp, err := client.Create(ctx, scope, name, armauthorization.RoleAssignmentCreateParameters{
+			Properties: &armauthorization.RoleAssignmentProperties{
+				RoleDefinitionID: to.Ptr(roleDefinitionId),
+				PrincipalID:      to.Ptr(principalId),
+				// skips the principal lookup that fails for principals created moments ago
+				PrincipalType: to.Ptr(armauthorization.PrincipalTypeServicePrincipal),
+			},
+		}, nil)
+		if err == nil {
+			if resp.ID == nil {
+				return nil, fmt.Errorf("role assignment id is nil")
+			}
+			id = *resp.ID
+			break
+		}
+
+		var respErr *azcore.ResponseError
+		if !errors.As(err, &respErr) {
+			return nil, fmt.Errorf("creating role assignment: %w", err)
+		}
+		if respErr.ErrorCode == "RoleAssignmentExists" {
+			lgr.Info("role assignment already exists")
+			id, err = existingRoleAssignmentId(ctx, client, scope, name, principalId, roleDefinitionId)
+			if err != nil {
+				return nil, fmt.Errorf("getting existing role assignment: %w", err)
+			}
+			break
+		}
+		if respErr.ErrorCode == "PrincipalNotFound" && time.Now().Before(deadline) {
+			lgr.Info("principal not replicated yet, retrying role assignment")
+			time.Sleep(10 * time.Second)
+			continue
+		}
 		return nil, fmt.Errorf("creating role assignment: %w", err)
 	}
 
-	return &roleAssignment{}, nil
+	if err := waitForRoleAssignment(ctx, client, id); err != nil {
+		return nil, err
+	}
+
+	return &roleAssignment{
+		id:          id,
+		principalId: principalId,
+		roleName:    role.Name,
+	}, nil
+}
+
+// Role assignment names have to be guids, a name based uuid keeps them stable across runs
+func roleAssignmentName(scope, principalId, roleDefinitionId string) string {
+	key := strings.ToLower(scope + "/" + principalId + "/" + roleDefinitionId)
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(key)).String()
+}
+
+// Returns the id of the assignment of roleDefinitionId to principalId at scope, which can have a name other than
+// name when it wasn't created by these tests
+func existingRoleAssignmentId(ctx context.Context, client *armauthorization.RoleAssignmentsClient, scope, name, principalId, roleDefinitionId string) (string, error) {
+	if resp, err := client.Get(ctx, scope, name, nil); err == nil && resp.ID != nil {
+		return *resp.ID, nil
+	}
+
+	pager := client.NewListForScopePager(scope, &armauthorization.RoleAssignmentsClientListForScopeOptions{
+		Filter: to.Ptr(fmt.Sprintf("principalId eq '%s'", principalId)),
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("listing role assignments: %w", err)
+		}
+		for _, ra := range page.Value {
+			if ra.ID == nil || ra.Properties == nil || ra.Properties.Scope == nil || ra.Properties.RoleDefinitionID == nil {
+				continue
+			}
+			if strings.EqualFold(*ra.Properties.Scope, scope) && strings.EqualFold(*ra.Properties.RoleDefinitionID, roleDefinitionId) {
+				return *ra.ID, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no role assignment of %s to %s at %s", roleDefinitionId, principalId, scope)
+}
+
+// Polls the role assignment until roleAssignmentReads reads in a row find it
+func waitForRoleAssignment(ctx context.Context, client *armauthorization.RoleAssignmentsClient, id string) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("waiting for role assignment to take effect")
+
+	timeout := time.Now().Add(roleAssignmentTimeout)
+	reads := 0
+	for {
+		if _, err := client.GetByID(ctx, id, nil); err != nil {
+			reads = 0
+		} else {
+			reads++
+		}
+		if reads == roleAssignmentReads {
+			return nil
+		}
+
+		if time.Now().After(timeout) {
+			return fmt.Errorf("role assignment %s not effective after %s", id, roleAssignmentTimeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// Returns whether the role assignment still exists
+func (r *roleAssignment) Exists(ctx context.Context) (bool, error) {
+	id, err := arm.ParseResourceID(r.id)
+	if err != nil {
+		return false, fmt.Errorf("parsing role assignment id: %w", err)
+	}
+
+	cred, err := GetAzCred()
+	if err != nil {
+		return false, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armauthorization.NewRoleAssignmentsClient(id.SubscriptionID, cred, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating client: %w", err)
+	}
+
+	if _, err := client.GetByID(ctx, r.id, nil); err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting role assignment: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *roleAssignment) GetPrincipalId() string {
+	return r.principalId
+}
+
+func (r *roleAssignment) GetRoleName() string {
+	return r.roleName
+}
+
+func (r *roleAssignment) GetId() string {
+	return r.id
 }
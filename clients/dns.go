@@ -3,6 +3,7 @@ package clients
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
@@ -50,6 +51,52 @@ func NewZone(ctx context.Context, subscriptionId, resourceGroup, name string, zo
 	name = nonAlphanumericRegex.ReplaceAllString(name, "")
 	name = name + ".com"
 
+	return createZone(ctx, subscriptionId, resourceGroup, name, zoneOpts...)
+}
+
+// Creates a zone for label nested under the existing zone parentName in the same resource group, then delegates to it
+// from the parent with an NS record set the same way a real subdomain zone would be set up
+func NewChildZone(ctx context.Context, subscriptionId, resourceGroup, parentName, label string, zoneOpts ...ZoneOpt) (*zone, error) {
+	label = nonAlphanumericRegex.ReplaceAllString(label, "")
+
+	child, err := createZone(ctx, subscriptionId, resourceGroup, label+"."+parentName, zoneOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	lgr := logger.FromContext(ctx).With("name", child.name, "parent", parentName, "subscriptionId", subscriptionId, "resourceGroup", resourceGroup)
+	lgr.Info("delegating child zone from parent")
+
+	cred, err := GetAzCred()
+	if err != nil {
+		return nil, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armdns.NewRecordSetsClient(subscriptionId, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating record sets client: %w", err)
+	}
+
+	nsRecords := make([]*armdns.NsRecord, len(child.nameservers))
+	for i, ns := range child.nameservers {
+		nsRecords[i] = &armdns.NsRecord{Nsdname: to.Ptr(ns)}
+	}
+
+	delegation := armdns.RecordSet{
+		Properties: &armdns.RecordSetProperties{
+			TTL:       to.Ptr[int64](3600),
+			NsRecords: nsRecords,
+		},
+	}
+	if _, err := client.CreateOrUpdate(ctx, resourceGroup, parentName, label, armdns.RecordTypeNS, delegation, nil); err != nil {
+		return nil, fmt.Errorf("creating delegation NS record set: %w", err)
+	}
+
+	return child, nil
+}
+
+// Creates the public zone name exactly as given
+func createZone(ctx context.Context, subscriptionId, resourceGroup, name string, zoneOpts ...ZoneOpt) (*zone, error) {
 	lgr := logger.FromContext(ctx).With("name", name, "subscriptionId", subscriptionId, "resourceGroup", resourceGroup)
 	ctx = logger.WithContext(ctx, lgr)
 	lgr.Info("starting to create zone")
@@ -149,6 +196,14 @@ func (z *zone) GetId() string {
 	return z.id
 }
 
+func (z *zone) GetSubscriptionId() string {
+	return z.subscriptionId
+}
+
+func (z *zone) GetResourceGroup() string {
+	return z.resourceGroup
+}
+
 // Loads provisioned private zone, used to convert .json saved to infrastructure file to a Provisioned object
 func LoadPrivateZone(id azure.Resource) *privateZone {
 	return &privateZone{
@@ -245,8 +300,7 @@ func (p *privateZone) GetDnsZone(ctx context.Context) (*armprivatedns.PrivateZon
 
 // Creates a virtual network link for new private dns zone
 func (p *privateZone) LinkVnet(ctx context.Context, linkName, vnetId string) error {
-	linkName = nonAlphanumericRegex.ReplaceAllString(linkName, "")
-	linkName = truncate(linkName, 80)
+	linkName = vnetLinkName(linkName)
 
 	lgr := logger.FromContext(ctx).With("name", p.name, "subscriptionId", p.subscriptionId, "resourceGroup", p.resourceGroup, "linkName", linkName, "vnetId", vnetId)
 	ctx = logger.WithContext(ctx, lgr)
@@ -281,6 +335,37 @@ func (p *privateZone) LinkVnet(ctx context.Context, linkName, vnetId string) err
 	return nil
 }
 
+// Returns whether the virtual network link LinkVnet created as linkName still exists and links vnetId
+func (p *privateZone) IsVnetLinked(ctx context.Context, linkName, vnetId string) (bool, error) {
+	cred, err := GetAzCred()
+	if err != nil {
+		return false, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armprivatedns.NewVirtualNetworkLinksClient(p.subscriptionId, cred, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating client: %w", err)
+	}
+
+	resp, err := client.Get(ctx, p.resourceGroup, p.name, vnetLinkName(linkName), nil)
+	if err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting virtual network link: %w", err)
+	}
+
+	props := resp.Properties
+	if props == nil || props.VirtualNetwork == nil || props.VirtualNetwork.ID == nil {
+		return false, nil
+	}
+	return strings.EqualFold(*props.VirtualNetwork.ID, vnetId), nil
+}
+
+func vnetLinkName(linkName string) string {
+	return truncate(nonAlphanumericRegex.ReplaceAllString(linkName, ""), 80)
+}
+
 func (p *privateZone) GetId() string {
 	return p.id
 }
@@ -0,0 +1,37 @@
+package clients
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/manifests"
+)
+
+// DNSEndpointCrdUrl installs the DNSEndpoint CRD matching the external dns version we deploy
+const DNSEndpointCrdUrl = "https://raw.githubusercontent.com/kubernetes-sigs/external-dns/v0.14.0/docs/contributing/crd-source/crd-manifest.yaml"
+
+// Returns the CRD DNSEndpointCrdUrl installs with only its name set, enough to look it up on a cluster
+func NewDNSEndpointCrdRef() *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName("dnsendpoints." + manifests.DNSEndpointGroupVersion.Group)
+	return crd
+}
+
+// Returns a DNSEndpoint publishing endpoints through the external dns crd source
+func NewDNSEndpoint(name string, endpoints ...*manifests.Endpoint) *manifests.DNSEndpoint {
+	return &manifests.DNSEndpoint{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "DNSEndpoint",
+			APIVersion: manifests.DNSEndpointGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kube-system",
+		},
+		Spec: manifests.DNSEndpointSpec{
+			Endpoints: endpoints,
+		},
+	}
+}
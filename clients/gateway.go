@@ -0,0 +1,94 @@
+package clients
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	// EnvoyGatewayInstallUrl installs the Gateway API CRDs along with the Envoy Gateway controller implementing them
+	EnvoyGatewayInstallUrl = "https://github.com/envoyproxy/gateway/releases/download/v1.0.1/install.yaml"
+
+	envoyGatewayControllerName = "gateway.envoyproxy.io/gatewayclass-controller"
+	gatewayClassName           = "envoy"
+
+	// GatewayName is the gateway every HTTPRoute in the tests attaches to
+	GatewayName = "e2e-gateway"
+)
+
+// Returns manifests for a GatewayClass served by Envoy Gateway and a gateway with a single http listener.
+// Envoy Gateway exposes the gateway through a public load balancer and writes its ip to the gateway status, which is what external dns reads
+func NewGatewayResources() []client.Object {
+	gatewayClass := &gatewayv1.GatewayClass{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "GatewayClass",
+			APIVersion: gatewayv1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: gatewayClassName,
+		},
+		Spec: gatewayv1.GatewayClassSpec{
+			ControllerName: envoyGatewayControllerName,
+		},
+	}
+
+	gateway := &gatewayv1.Gateway{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Gateway",
+			APIVersion: gatewayv1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GatewayName,
+			Namespace: "kube-system",
+		},
+		Spec: gatewayv1.GatewaySpec{
+			GatewayClassName: gatewayClassName,
+			Listeners: []gatewayv1.Listener{{
+				Name:     "http",
+				Protocol: gatewayv1.HTTPProtocolType,
+				Port:     80,
+			}},
+		},
+	}
+
+	return []client.Object{gatewayClass, gateway}
+}
+
+// Returns an HTTPRoute attached to gatewayName that routes hostnames to the ipv4 nginx service
+func NewHTTPRoute(name, gatewayName string, hostnames []string) *gatewayv1.HTTPRoute {
+	routeHostnames := make([]gatewayv1.Hostname, len(hostnames))
+	for i, hostname := range hostnames {
+		routeHostnames[i] = gatewayv1.Hostname(hostname)
+	}
+
+	return &gatewayv1.HTTPRoute{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "HTTPRoute",
+			APIVersion: gatewayv1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kube-system",
+		},
+		Spec: gatewayv1.HTTPRouteSpec{
+			CommonRouteSpec: gatewayv1.CommonRouteSpec{
+				ParentRefs: []gatewayv1.ParentReference{{
+					Name: gatewayv1.ObjectName(gatewayName),
+				}},
+			},
+			Hostnames: routeHostnames,
+			Rules: []gatewayv1.HTTPRouteRule{{
+				BackendRefs: []gatewayv1.HTTPBackendRef{{
+					BackendRef: gatewayv1.BackendRef{
+						BackendObjectReference: gatewayv1.BackendObjectReference{
+							Name: "nginx-svc-ipv4",
+							Port: to.Ptr(gatewayv1.PortNumber(80)),
+						},
+					},
+				}},
+			}},
+		},
+	}
+}
@@ -0,0 +1,183 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/msi/armmsi"
+	"github.com/Azure/go-autorest/autorest/azure"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+)
+
+// workloadIdentityAudience is the audience of service account tokens exchanged for Azure AD tokens
+const workloadIdentityAudience = "api://AzureADTokenExchange"
+
+// managedIdentity is a user assigned identity workloads in the cluster can federate with
+type managedIdentity struct {
+	name, subscriptionId, resourceGroup string
+	id                                  string
+	clientId, principalId               string
+}
+
+// Called when loading provisioned infrastructure from .json file
+func LoadManagedIdentity(id azure.Resource, clientId, principalId string) *managedIdentity {
+	return &managedIdentity{
+		name:           id.ResourceName,
+		subscriptionId: id.SubscriptionID,
+		resourceGroup:  id.ResourceGroup,
+		id:             id.String(),
+		clientId:       clientId,
+		principalId:    principalId,
+	}
+}
+
+// Creates a user assigned managed identity
+func NewManagedIdentity(ctx context.Context, subscriptionId, resourceGroup, name, location string) (*managedIdentity, error) {
+	name = truncate(nonAlphanumericRegex.ReplaceAllString(name, ""), 128)
+
+	lgr := logger.FromContext(ctx).With("name", name, "subscriptionId", subscriptionId, "resourceGroup", resourceGroup)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to create managed identity")
+	defer lgr.Info("finished creating managed identity")
+
+	cred, err := GetAzCred()
+	if err != nil {
+		return nil, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armmsi.NewUserAssignedIdentitiesClient(subscriptionId, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	resp, err := client.CreateOrUpdate(ctx, resourceGroup, name, armmsi.Identity{
+		Location: to.Ptr(location),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating managed identity: %w", err)
+	}
+
+	// guard against things that should be impossible
+	if resp.ID == nil {
+		return nil, fmt.Errorf("managed identity id is nil")
+	}
+	if resp.Properties == nil || resp.Properties.ClientID == nil || resp.Properties.PrincipalID == nil {
+		return nil, fmt.Errorf("managed identity client or principal id is nil")
+	}
+
+	return &managedIdentity{
+		name:           name,
+		subscriptionId: subscriptionId,
+		resourceGroup:  resourceGroup,
+		id:             *resp.ID,
+		clientId:       *resp.Properties.ClientID,
+		principalId:    *resp.Properties.PrincipalID,
+	}, nil
+}
+
+// Trusts tokens issuerUrl issues for the service account so pods running as it can authenticate as the identity.
+// Azure rejects concurrent writes to the credentials of one identity so calls for the same identity must not overlap
+func (m *managedIdentity) FederateServiceAccount(ctx context.Context, issuerUrl, namespace, serviceAccount string) error {
+	subject := serviceAccountSubject(namespace, serviceAccount)
+	credentialName := federatedCredentialName(namespace, serviceAccount)
+
+	lgr := logger.FromContext(ctx).With("name", m.name, "subscriptionId", m.subscriptionId, "resourceGroup", m.resourceGroup, "subject", subject)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to create federated identity credential")
+	defer lgr.Info("finished creating federated identity credential")
+
+	cred, err := GetAzCred()
+	if err != nil {
+		return fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armmsi.NewFederatedIdentityCredentialsClient(m.subscriptionId, cred, nil)
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+
+	_, err = client.CreateOrUpdate(ctx, m.resourceGroup, m.name, credentialName, armmsi.FederatedIdentityCredential{
+		Properties: &armmsi.FederatedIdentityCredentialProperties{
+			Audiences: []*string{to.Ptr(workloadIdentityAudience)},
+			Issuer:    to.Ptr(issuerUrl),
+			Subject:   to.Ptr(subject),
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("creating federated identity credential: %w", err)
+	}
+
+	return nil
+}
+
+// Returns whether the identity still exists
+func (m *managedIdentity) Exists(ctx context.Context) (bool, error) {
+	cred, err := GetAzCred()
+	if err != nil {
+		return false, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armmsi.NewUserAssignedIdentitiesClient(m.subscriptionId, cred, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating client: %w", err)
+	}
+
+	if _, err := client.Get(ctx, m.resourceGroup, m.name, nil); err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting managed identity: %w", err)
+	}
+
+	return true, nil
+}
+
+// Returns whether the federated identity credential FederateServiceAccount creates for the service account still
+// exists and trusts tokens issuerUrl issues for it
+func (m *managedIdentity) IsFederated(ctx context.Context, issuerUrl, namespace, serviceAccount string) (bool, error) {
+	cred, err := GetAzCred()
+	if err != nil {
+		return false, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armmsi.NewFederatedIdentityCredentialsClient(m.subscriptionId, cred, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating client: %w", err)
+	}
+
+	resp, err := client.Get(ctx, m.resourceGroup, m.name, federatedCredentialName(namespace, serviceAccount), nil)
+	if err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting federated identity credential: %w", err)
+	}
+
+	props := resp.Properties
+	if props == nil || props.Issuer == nil || props.Subject == nil {
+		return false, nil
+	}
+	return *props.Issuer == issuerUrl && *props.Subject == serviceAccountSubject(namespace, serviceAccount), nil
+}
+
+func serviceAccountSubject(namespace, serviceAccount string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount)
+}
+
+func federatedCredentialName(namespace, serviceAccount string) string {
+	return truncate(nonAlphanumericRegex.ReplaceAllString(namespace+"-"+serviceAccount, ""), 120)
+}
+
+func (m *managedIdentity) GetClientId() string {
+	return m.clientId
+}
+
+func (m *managedIdentity) GetPrincipalId() string {
+	return m.principalId
+}
+
+func (m *managedIdentity) GetId() string {
+	return m.id
+}
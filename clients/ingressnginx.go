@@ -0,0 +1,282 @@
+package clients
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ingressNginxName  = "ingress-nginx"
+	ingressNginxImage = "registry.k8s.io/ingress-nginx/controller:v1.9.4"
+
+	// PublicIngressClass is served by an ingress controller behind a public load balancer
+	PublicIngressClass = "nginx-public"
+	// InternalIngressClass is served by an ingress controller behind an internal load balancer
+	InternalIngressClass = "nginx-internal"
+)
+
+// IngressControllerServiceName returns the name of the load balancer service in front of the controller for ingressClass
+func IngressControllerServiceName(ingressClass string) string {
+	return ingressClass + "-controller"
+}
+
+// Returns manifests for two ingress-nginx controllers, one behind a public and one behind an internal load balancer.
+// The controllers publish their load balancer ip to the status of the ingresses they serve, which is what external dns reads
+func NewIngressNginxResources() []client.Object {
+	objs := []client.Object{
+		&corev1.ServiceAccount{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "ServiceAccount",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ingressNginxName,
+				Namespace: "kube-system",
+			},
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "ClusterRole",
+				APIVersion: "rbac.authorization.k8s.io/v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: ingressNginxName,
+			},
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups: []string{""},
+					Resources: []string{"configmaps", "endpoints", "nodes", "pods", "secrets", "namespaces", "services"},
+					Verbs:     []string{"get", "list", "watch"},
+				},
+				{
+					APIGroups: []string{""},
+					Resources: []string{"events"},
+					Verbs:     []string{"create", "patch"},
+				},
+				{
+					APIGroups: []string{"coordination.k8s.io"},
+					Resources: []string{"leases"},
+					Verbs:     []string{"get", "list", "watch", "create", "update"},
+				},
+				{
+					APIGroups: []string{"discovery.k8s.io"},
+					Resources: []string{"endpointslices"},
+					Verbs:     []string{"get", "list", "watch"},
+				},
+				{
+					APIGroups: []string{"networking.k8s.io"},
+					Resources: []string{"ingresses", "ingressclasses"},
+					Verbs:     []string{"get", "list", "watch"},
+				},
+				{
+					APIGroups: []string{"networking.k8s.io"},
+					Resources: []string{"ingresses/status"},
+					Verbs:     []string{"update"},
+				},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "ClusterRoleBinding",
+				APIVersion: "rbac.authorization.k8s.io/v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: ingressNginxName,
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     ingressNginxName,
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind:      "ServiceAccount",
+				Name:      ingressNginxName,
+				Namespace: "kube-system",
+			}},
+		},
+	}
+
+	objs = append(objs, newIngressNginxController(PublicIngressClass, false)...)
+	objs = append(objs, newIngressNginxController(InternalIngressClass, true)...)
+	return objs
+}
+
+// Returns the ingress class, deployment and load balancer service for a single ingress-nginx controller
+func newIngressNginxController(ingressClass string, internal bool) []client.Object {
+	controllerClass := "k8s.io/" + ingressClass
+	labels := map[string]string{"app": ingressClass}
+
+	svcAnnotations := map[string]string{}
+	if internal {
+		svcAnnotations["service.beta.kubernetes.io/azure-load-balancer-internal"] = "true"
+	}
+
+	ingClass := &networkingv1.IngressClass{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "IngressClass",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ingressClass,
+		},
+		Spec: networkingv1.IngressClassSpec{
+			Controller: controllerClass,
+		},
+	}
+
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ingressClass,
+			Namespace: "kube-system",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: to.Ptr(int32(1)),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: *WithPreferSystemNodes(&corev1.PodSpec{
+					ServiceAccountName: ingressNginxName,
+					Containers: []corev1.Container{
+						{
+							Name:  "controller",
+							Image: ingressNginxImage,
+							Args: []string{
+								"/nginx-ingress-controller",
+								"--publish-service=$(POD_NAMESPACE)/" + IngressControllerServiceName(ingressClass),
+								"--election-id=" + ingressClass + "-leader",
+								"--controller-class=" + controllerClass,
+								"--ingress-class=" + ingressClass,
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name:      "POD_NAME",
+									ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+								},
+								{
+									Name:      "POD_NAMESPACE",
+									ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
+								},
+							},
+							Ports: []corev1.ContainerPort{
+								{Name: "http", ContainerPort: 80},
+								{Name: "https", ContainerPort: 443},
+							},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									HTTPGet: &corev1.HTTPGetAction{
+										Path: "/healthz",
+										Port: intstr.FromInt(10254),
+									},
+								},
+							},
+							SecurityContext: &corev1.SecurityContext{
+								RunAsUser:                to.Ptr(int64(101)),
+								AllowPrivilegeEscalation: to.Ptr(true),
+								Capabilities: &corev1.Capabilities{
+									Add:  []corev1.Capability{"NET_BIND_SERVICE"},
+									Drop: []corev1.Capability{"ALL"},
+								},
+							},
+						},
+					},
+				}),
+			},
+		},
+	}
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        IngressControllerServiceName(ingressClass),
+			Namespace:   "kube-system",
+			Annotations: svcAnnotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeLoadBalancer,
+			Selector: labels,
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromString("http"),
+				},
+				{
+					Name:       "https",
+					Protocol:   "TCP",
+					Port:       443,
+					TargetPort: intstr.FromString("https"),
+				},
+			},
+		},
+	}
+
+	return []client.Object{ingClass, deployment, service}
+}
+
+// Returns an ingress served by ingressClass with a rule for every host and a tls section listing tlsHosts.
+// tlsHosts are deliberately left out of the rules so that records created for them can only come from the tls section
+func NewIngress(name, ingressClass string, hosts, tlsHosts []string) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	backend := networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{
+			Name: "nginx-svc-ipv4",
+			Port: networkingv1.ServiceBackendPort{Number: 80},
+		},
+	}
+
+	var rules []networkingv1.IngressRule
+	for _, host := range hosts {
+		rules = append(rules, networkingv1.IngressRule{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend:  backend,
+					}},
+				},
+			},
+		})
+	}
+
+	var tls []networkingv1.IngressTLS
+	if len(tlsHosts) > 0 {
+		tls = []networkingv1.IngressTLS{{
+			Hosts:      tlsHosts,
+			SecretName: name + "-tls", // doesn't need to exist, nginx falls back to its default certificate
+		}}
+	}
+
+	return &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Ingress",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kube-system",
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: to.Ptr(ingressClass),
+			Rules:            rules,
+			TLS:              tls,
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+)
+
+// Returns the major.minor kubernetes versions AKS supports creating clusters on in location, oldest first. Preview
+// versions are left out since they can be withdrawn between provisioning and testing
+func ListKubernetesVersions(ctx context.Context, subscriptionId, location string) ([]string, error) {
+	lgr := logger.FromContext(ctx).With("subscriptionId", subscriptionId, "location", location)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to list kubernetes versions")
+	defer lgr.Info("finished listing kubernetes versions")
+
+	cred, err := GetAzCred()
+	if err != nil {
+		return nil, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armcontainerservice.NewManagedClustersClient(subscriptionId, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating aks client: %w", err)
+	}
+
+	resp, err := client.ListKubernetesVersions(ctx, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("listing kubernetes versions: %w", err)
+	}
+
+	var versions []string
+	for _, v := range resp.Values {
+		if v == nil || v.Version == nil {
+			continue
+		}
+		if v.IsPreview != nil && *v.IsPreview {
+			continue
+		}
+		if _, _, err := parseMinorVersion(*v.Version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v.Version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		iMajor, iMinor, _ := parseMinorVersion(versions[i])
+		jMajor, jMinor, _ := parseMinorVersion(versions[j])
+		if iMajor != jMajor {
+			return iMajor < jMajor
+		}
+		return iMinor < jMinor
+	})
+
+	return versions, nil
+}
+
+// Splits a major.minor version into its numbers
+func parseMinorVersion(version string) (int, int, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("kubernetes version %q isn't a major.minor version", version)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing major version of %q: %w", version, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing minor version of %q: %w", version, err)
+	}
+
+	return major, minor, nil
+}
@@ -47,23 +47,81 @@ func NewNginxDeployment() *appsv1.Deployment {
 	}
 }
 
-// Returns nginx services with necessary config to create ipv4 and ipv6 records
-func NewNginxServices(zoneName string) (*corev1.Service, *corev1.Service) {
-	ipv4Service := &corev1.Service{
+// ServiceType selects how NewNginxService exposes the nginx deployment
+type ServiceType int
+
+const (
+	LoadBalancerService ServiceType = iota
+	// HeadlessService has no cluster ip, external dns publishes the ips of the pods backing it
+	HeadlessService
+	// NodePortService is published by external dns as the ips of the nodes plus SRV records for the node ports
+	NodePortService
+)
+
+// ServiceOpt customizes a service built by NewNginxService
+type ServiceOpt func(svc *corev1.Service)
+
+// WithIPFamily makes the service single stack in family
+func WithIPFamily(family corev1.IPFamily) ServiceOpt {
+	return func(svc *corev1.Service) {
+		svc.Spec.IPFamilies = []corev1.IPFamily{family}
+	}
+}
+
+// WithAnnotations sets annotations on the service, e.g. the hostname annotation external dns reads
+func WithAnnotations(annotations map[string]string) ServiceOpt {
+	return func(svc *corev1.Service) {
+		if svc.Annotations == nil {
+			svc.Annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			svc.Annotations[k] = v
+		}
+	}
+}
+
+// WithNodePort pins the node port of a NodePort service so tests know the port published in SRV records
+func WithNodePort(port int32) ServiceOpt {
+	return func(svc *corev1.Service) {
+		svc.Spec.Ports[0].NodePort = port
+	}
+}
+
+// WithLabels sets labels on the service
+func WithLabels(labels map[string]string) ServiceOpt {
+	return func(svc *corev1.Service) {
+		if svc.Labels == nil {
+			svc.Labels = map[string]string{}
+		}
+		for k, v := range labels {
+			svc.Labels[k] = v
+		}
+	}
+}
+
+// WithNamespace moves the service out of kube-system, the namespace has to exist
+func WithNamespace(namespace string) ServiceOpt {
+	return func(svc *corev1.Service) {
+		svc.Namespace = namespace
+	}
+}
+
+// Returns a service of serviceType in front of the nginx deployment
+func NewNginxService(name string, serviceType ServiceType, opts ...ServiceOpt) *corev1.Service {
+	svc := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Service",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "nginx-svc-ipv4",
+			Name:      name,
 			Namespace: "kube-system",
 		},
 		Spec: corev1.ServiceSpec{
-			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyCluster,
-			Type:                  corev1.ServiceTypeLoadBalancer,
-			Selector:              map[string]string{"app": "nginx"},
+			Selector: map[string]string{"app": "nginx"},
 			Ports: []corev1.ServicePort{
 				{
+					Name:       "http",
 					Protocol:   "TCP",
 					Port:       80,
 					TargetPort: intstr.FromInt(80),
@@ -72,31 +130,69 @@ func NewNginxServices(zoneName string) (*corev1.Service, *corev1.Service) {
 		},
 	}
 
-	ipv6Service := &corev1.Service{
+	switch serviceType {
+	case LoadBalancerService:
+		svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+		svc.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyCluster
+	case HeadlessService:
+		svc.Spec.Type = corev1.ServiceTypeClusterIP
+		svc.Spec.ClusterIP = corev1.ClusterIPNone
+	case NodePortService:
+		// cluster policy makes external dns publish every node rather than only the nodes running nginx
+		svc.Spec.Type = corev1.ServiceTypeNodePort
+		svc.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyCluster
+	}
+
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	return svc
+}
+
+// Returns nginx services with necessary config to create ipv4 and ipv6 records
+func NewNginxServices(zoneName string) (*corev1.Service, *corev1.Service) {
+	// pinned to ipv4 so it keeps an ipv4 address on clusters where ipv6 is the primary family
+	ipv4Service := NewNginxService("nginx-svc-ipv4", LoadBalancerService, WithIPFamily(corev1.IPv4Protocol))
+	ipv6Service := NewNginxService("nginx-svc-ipv6", LoadBalancerService, WithIPFamily(corev1.IPv6Protocol))
+	return ipv4Service, ipv6Service
+}
+
+// Returns a service of type ExternalName that resolves to externalName, used to create CNAME records
+func NewExternalNameService(name, externalName string, opts ...ServiceOpt) *corev1.Service {
+	svc := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Service",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "nginx-svc-ipv6",
+			Name:      name,
 			Namespace: "kube-system",
 		},
 		Spec: corev1.ServiceSpec{
-			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyCluster,
-			Type:                  corev1.ServiceTypeLoadBalancer,
-			Selector:              map[string]string{"app": "nginx"},
-			Ports: []corev1.ServicePort{
-				{
-					Protocol:   "TCP",
-					Port:       80,
-					TargetPort: intstr.FromInt(80),
-				},
-			},
-			IPFamilies: []corev1.IPFamily{corev1.IPv6Protocol},
+			Type:         corev1.ServiceTypeExternalName,
+			ExternalName: externalName,
 		},
 	}
 
-	return ipv4Service, ipv6Service
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	return svc
+}
+
+// Returns a namespace for tests that need objects outside kube-system
+func NewNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Namespace",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
 }
 
 func WithPreferSystemNodes(spec *corev1.PodSpec) *corev1.PodSpec {
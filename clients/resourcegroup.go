@@ -13,8 +13,9 @@ import (
 )
 
 type rg struct {
-	name string
-	id   string
+	name           string
+	subscriptionId string
+	id             string
 }
 
 type RgOpt func(rg *armresources.ResourceGroup) error
@@ -36,8 +37,9 @@ func DeleteAfterOpt(d time.Duration) RgOpt {
 // Called when loading provisioned infrastructure from .json file, returns an rg struct
 func LoadRg(id arm.ResourceID) *rg {
 	return &rg{
-		id:   id.String(),
-		name: id.Name,
+		id:             id.String(),
+		name:           id.Name,
+		subscriptionId: id.SubscriptionID,
 	}
 }
 
@@ -81,8 +83,9 @@ func NewResourceGroup(ctx context.Context, subscriptionId, name, location string
 	}
 
 	return &rg{
-		name: *resp.Name,
-		id:   *resp.ID,
+		name:           *resp.Name,
+		subscriptionId: subscriptionId,
+		id:             *resp.ID,
 	}, nil
 }
 
@@ -93,3 +96,23 @@ func (r *rg) GetName() string {
 func (r *rg) GetId() string {
 	return r.id
 }
+
+// Returns whether the resource group still exists, resource groups are deleted by the garbage collector once they're due
+func (r *rg) Exists(ctx context.Context) (bool, error) {
+	cred, err := GetAzCred()
+	if err != nil {
+		return false, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	client, err := armresources.NewResourceGroupsClient(r.subscriptionId, cred, nil)
+	if err != nil {
+		return false, fmt.Errorf("creating resource group client: %w", err)
+	}
+
+	resp, err := client.CheckExistence(ctx, r.name, nil)
+	if err != nil {
+		return false, fmt.Errorf("checking resource group existence: %w", err)
+	}
+
+	return resp.Success, nil
+}
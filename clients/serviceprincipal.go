@@ -0,0 +1,297 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+)
+
+const (
+	graphEndpoint = "https://graph.microsoft.com/v1.0"
+	graphScope    = "https://graph.microsoft.com/.default"
+)
+
+// servicePrincipal is an app registration and its service principal, authenticated to with a client secret.
+// Clusters outside AKS have no managed identity so this is the only way they can run external dns
+type servicePrincipal struct {
+	// appObjectId is the object id of the app registration, clientId is its app id
+	appObjectId string
+	clientId    string
+	// principalId is the object id of the service principal, roles are assigned to it
+	principalId string
+	// tags mark the run and infrastructure the app registration was created for so it can be found and deleted
+	tags []string
+	// secret is never saved with the infrastructure, a loaded service principal has none until NewSecret is called
+	secret string
+}
+
+type graphApplication struct {
+	Id          string   `json:"id,omitempty"`
+	AppId       string   `json:"appId,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+type graphApplicationList struct {
+	Value    []graphApplication `json:"value"`
+	NextLink string             `json:"@odata.nextLink,omitempty"`
+}
+
+type graphServicePrincipal struct {
+	Id    string `json:"id,omitempty"`
+	AppId string `json:"appId,omitempty"`
+}
+
+type graphPasswordCredential struct {
+	DisplayName string    `json:"displayName,omitempty"`
+	EndDateTime time.Time `json:"endDateTime,omitempty"`
+	SecretText  string    `json:"secretText,omitempty"`
+}
+
+// Called when loading provisioned infrastructure from .json file
+func LoadServicePrincipal(appObjectId, clientId, principalId string, tags []string) *servicePrincipal {
+	return &servicePrincipal{
+		appObjectId: appObjectId,
+		clientId:    clientId,
+		principalId: principalId,
+		tags:        tags,
+	}
+}
+
+// Creates an app registration tagged with tags, its service principal and a client secret that expires after
+// secretLifetime. The identity running the e2e tests needs permission to create applications in the tenant, it's made
+// an owner of the app registration which lets it delete the app again with Application.ReadWrite.OwnedBy
+func NewServicePrincipal(ctx context.Context, name string, tags []string, secretLifetime time.Duration) (*servicePrincipal, error) {
+	lgr := logger.FromContext(ctx).With("name", name)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to create service principal")
+	defer lgr.Info("finished creating service principal")
+
+	pl, err := graphPipeline()
+	if err != nil {
+		return nil, err
+	}
+
+	app := &graphApplication{}
+	if err := graphPost(ctx, pl, "/applications", graphApplication{DisplayName: name, Tags: tags}, app); err != nil {
+		return nil, fmt.Errorf("creating app registration: %w", err)
+	}
+
+	sp := &graphServicePrincipal{}
+	if err := graphPost(ctx, pl, "/servicePrincipals", graphServicePrincipal{AppId: app.AppId}, sp); err != nil {
+		return nil, fmt.Errorf("creating service principal: %w", err)
+	}
+
+	// guard against things that should be impossible
+	if app.Id == "" || app.AppId == "" || sp.Id == "" {
+		return nil, fmt.Errorf("service principal is missing an id")
+	}
+
+	ret := &servicePrincipal{
+		appObjectId: app.Id,
+		clientId:    app.AppId,
+		principalId: sp.Id,
+		tags:        tags,
+	}
+	if err := ret.addPassword(ctx, pl, secretLifetime); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Adds a client secret to the app registration that expires after lifetime and signs in with it from then on. Only
+// the app's object id is needed so a service principal loaded from a .json file gets a secret without one being saved
+func (s *servicePrincipal) NewSecret(ctx context.Context, lifetime time.Duration) error {
+	lgr := logger.FromContext(ctx).With("appObjectId", s.appObjectId)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to create client secret")
+	defer lgr.Info("finished creating client secret")
+
+	pl, err := graphPipeline()
+	if err != nil {
+		return err
+	}
+
+	return s.addPassword(ctx, pl, lifetime)
+}
+
+func (s *servicePrincipal) addPassword(ctx context.Context, pl runtime.Pipeline, lifetime time.Duration) error {
+	password := &graphPasswordCredential{}
+	body := map[string]graphPasswordCredential{
+		"passwordCredential": {DisplayName: "external-dns-e2e", EndDateTime: time.Now().Add(lifetime).UTC()},
+	}
+	if err := graphPost(ctx, pl, "/applications/"+s.appObjectId+"/addPassword", body, password); err != nil {
+		return fmt.Errorf("creating client secret: %w", err)
+	}
+
+	// guard against things that should be impossible
+	if password.SecretText == "" {
+		return fmt.Errorf("client secret is empty")
+	}
+
+	s.secret = password.SecretText
+	return nil
+}
+
+// Returns every app registration tagged with tag as a service principal without a secret. Only the app registration is
+// looked up, principal ids aren't set
+func ListServicePrincipals(ctx context.Context, tag string) ([]*servicePrincipal, error) {
+	pl, err := graphPipeline()
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("$filter", fmt.Sprintf("tags/any(t:t eq '%s')", strings.ReplaceAll(tag, "'", "''")))
+	next := graphEndpoint + "/applications?" + query.Encode()
+
+	var ret []*servicePrincipal
+	for next != "" {
+		list := &graphApplicationList{}
+		if err := graphGet(ctx, pl, next, list); err != nil {
+			return nil, fmt.Errorf("listing app registrations tagged %s: %w", tag, err)
+		}
+		for _, app := range list.Value {
+			ret = append(ret, &servicePrincipal{appObjectId: app.Id, clientId: app.AppId, tags: app.Tags})
+		}
+		next = list.NextLink
+	}
+
+	return ret, nil
+}
+
+// Deletes the app registration, which deletes its service principal with it. Deleting the resource groups doesn't
+// remove either since they live in the tenant, not the subscription
+func (s *servicePrincipal) Delete(ctx context.Context) error {
+	lgr := logger.FromContext(ctx).With("appObjectId", s.appObjectId)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to delete app registration")
+	defer lgr.Info("finished deleting app registration")
+
+	pl, err := graphPipeline()
+	if err != nil {
+		return err
+	}
+
+	req, err := runtime.NewRequest(ctx, http.MethodDelete, graphEndpoint+"/applications/"+s.appObjectId)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := pl.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	// an app registration that's already gone is as good as deleted
+	if !runtime.HasStatusCode(resp, http.StatusNoContent, http.StatusNotFound) {
+		return runtime.NewResponseError(resp)
+	}
+
+	return nil
+}
+
+// Returns a pipeline authenticated to Microsoft Graph with the credential used to provision all infrastructure
+func graphPipeline() (runtime.Pipeline, error) {
+	cred, err := GetAzCred()
+	if err != nil {
+		return runtime.Pipeline{}, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	return runtime.NewPipeline("externaldnse2e", "v0.0.0", runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(cred, []string{graphScope}, nil)},
+	}, nil), nil
+}
+
+// Posts body to the graph path and unmarshals the created object into out
+func graphPost(ctx context.Context, pl runtime.Pipeline, path string, body, out interface{}) error {
+	req, err := runtime.NewRequest(ctx, http.MethodPost, graphEndpoint+path)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	if err := runtime.MarshalAsJSON(req, body); err != nil {
+		return fmt.Errorf("marshaling request: %w", err)
+	}
+
+	resp, err := pl.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusCreated) {
+		return runtime.NewResponseError(resp)
+	}
+
+	if err := runtime.UnmarshalAsJSON(resp, out); err != nil {
+		return fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return nil
+}
+
+// Gets the object at the graph link and unmarshals it into out
+func graphGet(ctx context.Context, pl runtime.Pipeline, link string, out interface{}) error {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, link)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := pl.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return runtime.NewResponseError(resp)
+	}
+
+	if err := runtime.UnmarshalAsJSON(resp, out); err != nil {
+		return fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return nil
+}
+
+// Returns whether the app registration and its service principal still exist
+func (s *servicePrincipal) Exists(ctx context.Context) (bool, error) {
+	pl, err := graphPipeline()
+	if err != nil {
+		return false, err
+	}
+
+	for _, link := range []string{graphEndpoint + "/applications/" + s.appObjectId, graphEndpoint + "/servicePrincipals/" + s.principalId} {
+		if err := graphGet(ctx, pl, link, &struct{}{}); err != nil {
+			if IsNotFound(err) {
+				return false, nil
+			}
+			return false, fmt.Errorf("getting %s: %w", link, err)
+		}
+	}
+
+	return true, nil
+}
+
+func (s *servicePrincipal) GetClientId() string {
+	return s.clientId
+}
+
+func (s *servicePrincipal) GetPrincipalId() string {
+	return s.principalId
+}
+
+func (s *servicePrincipal) GetTags() []string {
+	return s.tags
+}
+
+func (s *servicePrincipal) GetSecret() string {
+	return s.secret
+}
+
+// GetId returns the object id of the app registration
+func (s *servicePrincipal) GetId() string {
+	return s.appObjectId
+}
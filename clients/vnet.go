@@ -1,111 +1,257 @@
 package clients
 
-//code obtained from azure-sdk-for-go-samples/sdk/resourcemanager/network/networkInterface
-
 import (
 	"context"
 	"fmt"
-	"log"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v2"
-)
+	"github.com/Azure/go-autorest/autorest/azure"
 
-var (
-	subscriptionID     string
-	resourceGroupName  string
-	location           string
-	virtualNetworkName = "sample-virtual-network"
-	subnetName         = "sample-subnet"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+)
 
-	virtualNetworksClient *armnetwork.VirtualNetworksClient
-	subnetsClient         *armnetwork.SubnetsClient
-	networkClientFactory  *armnetwork.ClientFactory
+const (
+	// NodeSubnetName is the subnet cluster nodes are placed in, every layout needs it
+	NodeSubnetName = "nodes"
+	// InternalLbSubnetName is a dedicated subnet for internal load balancers, selected with the azure-load-balancer-internal-subnet annotation
+	InternalLbSubnetName = "internal-lb-subnet"
 )
 
-func NewVnet(ctx context.Context, subId, rg, region, privateZoneName string) (string, string, error) {
-	subscriptionID = subId
-	resourceGroupName = rg
-	location = region
+// VnetLayout is the address space of the vnet and its subnets. Dual stack clusters need an ipv4 and an ipv6 range in
+// the vnet and in the node subnet, ipv4 only clusters can leave the ipv6 ranges empty
+type VnetLayout struct {
+	Ipv4AddressSpace string
+	Ipv6AddressSpace string
+	// Subnets must include one named NodeSubnetName
+	Subnets []SubnetLayout
+}
+
+// SubnetLayout is a subnet's name and its range in each ip family of the vnet
+type SubnetLayout struct {
+	Name       string
+	Ipv4Prefix string
+	Ipv6Prefix string
+}
 
-	cred, err := GetAzCred()
-	if err != nil {
-		return "", "", fmt.Errorf("getting az credentials: %w", err)
+// DefaultVnetLayout is used by infrastructure that doesn't define its own layout
+var DefaultVnetLayout = VnetLayout{
+	Ipv4AddressSpace: "10.1.0.0/16",
+	Ipv6AddressSpace: "fd00:db8:deca::/48",
+	Subnets: []SubnetLayout{
+		{Name: NodeSubnetName, Ipv4Prefix: "10.1.0.0/24", Ipv6Prefix: "fd00:db8:deca:deed::/64"},
+		{Name: InternalLbSubnetName, Ipv4Prefix: "10.1.1.0/24", Ipv6Prefix: "fd00:db8:deca:deee::/64"},
+	},
+}
+
+// AzureCniVnetLayout is used by ipv4 only Azure CNI clusters. Pods take ips from the node subnet so it's sized for
+// every node's max pods plus upgrade surge rather than just the nodes
+var AzureCniVnetLayout = VnetLayout{
+	Ipv4AddressSpace: "10.1.0.0/16",
+	Subnets: []SubnetLayout{
+		{Name: NodeSubnetName, Ipv4Prefix: "10.1.0.0/20"},
+		{Name: InternalLbSubnetName, Ipv4Prefix: "10.1.16.0/24"},
+	},
+}
+
+// Returns an error if the layout has no address space, no node subnet, or a subnet without a range in the vnet
+func (l VnetLayout) Validate() error {
+	if l.Ipv4AddressSpace == "" && l.Ipv6AddressSpace == "" {
+		return fmt.Errorf("vnet needs an ipv4 or ipv6 address space")
 	}
 
-	networkClientFactory, err = armnetwork.NewClientFactory(subscriptionID, cred, nil)
-	if err != nil {
-		log.Fatal(err)
+	names := map[string]struct{}{}
+	for _, s := range l.Subnets {
+		if s.Name == "" {
+			return fmt.Errorf("subnet needs a name")
+		}
+		if _, ok := names[s.Name]; ok {
+			return fmt.Errorf("subnet %s is defined more than once", s.Name)
+		}
+		names[s.Name] = struct{}{}
+
+		if len(s.Prefixes()) == 0 {
+			return fmt.Errorf("subnet %s needs an ipv4 or ipv6 prefix", s.Name)
+		}
+		if s.Ipv4Prefix != "" && l.Ipv4AddressSpace == "" {
+			return fmt.Errorf("subnet %s has an ipv4 prefix but the vnet has no ipv4 address space", s.Name)
+		}
+		if s.Ipv6Prefix != "" && l.Ipv6AddressSpace == "" {
+			return fmt.Errorf("subnet %s has an ipv6 prefix but the vnet has no ipv6 address space", s.Name)
+		}
+	}
+
+	if _, ok := names[NodeSubnetName]; !ok {
+		return fmt.Errorf("vnet needs a subnet named %s for the cluster nodes", NodeSubnetName)
+	}
+
+	return nil
+}
+
+// Returns the subnet named name
+func (l VnetLayout) Subnet(name string) (SubnetLayout, bool) {
+	for _, s := range l.Subnets {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return SubnetLayout{}, false
+}
+
+// Returns the address prefixes of the vnet, ipv6 first
+func (l VnetLayout) addressPrefixes() []string {
+	return nonEmpty(l.Ipv6AddressSpace, l.Ipv4AddressSpace)
+}
+
+// Returns the ranges of the subnet, ipv6 first
+func (s SubnetLayout) Prefixes() []string {
+	return nonEmpty(s.Ipv6Prefix, s.Ipv4Prefix)
+}
+
+func nonEmpty(values ...string) []string {
+	var ret []string
+	for _, v := range values {
+		if v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// vnet is a virtual network and its subnets, each infra creates its own
+type vnet struct {
+	name, subscriptionId, resourceGroup string
+	id                                  string
+	// subnetIds are the ids of the subnets keyed by name
+	subnetIds map[string]string
+}
+
+// Called when loading provisioned infrastructure from .json file
+func LoadVnet(id azure.Resource, subnetIds map[string]string) *vnet {
+	return &vnet{
+		name:           id.ResourceName,
+		subscriptionId: id.SubscriptionID,
+		resourceGroup:  id.ResourceGroup,
+		id:             id.String(),
+		subnetIds:      subnetIds,
 	}
+}
+
+// Creates a vnet with the subnets in layout. The subnets are created with the vnet in one request since Azure rejects
+// concurrent writes to subnets of the same vnet
+func NewVnet(ctx context.Context, subscriptionId, resourceGroup, name, location string, layout VnetLayout) (*vnet, error) {
+	name = truncate(nonAlphanumericRegex.ReplaceAllString(name, ""), 64)
 
-	virtualNetworksClient = networkClientFactory.NewVirtualNetworksClient()
-	subnetsClient = networkClientFactory.NewSubnetsClient()
+	lgr := logger.FromContext(ctx).With("name", name, "subscriptionId", subscriptionId, "resourceGroup", resourceGroup)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to create vnet")
+	defer lgr.Info("finished creating vnet")
+
+	if err := layout.Validate(); err != nil {
+		return nil, fmt.Errorf("validating vnet layout: %w", err)
+	}
 
-	virtualNetwork, err := createVirtualNetwork(ctx)
+	cred, err := GetAzCred()
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("getting az credentials: %w", err)
 	}
 
-	subnet, err := createSubnet(ctx)
+	client, err := armnetwork.NewVirtualNetworksClient(subscriptionId, cred, nil)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	return *virtualNetwork.ID, *subnet.ID, nil
-}
-
-func createVirtualNetwork(ctx context.Context) (*armnetwork.VirtualNetwork, error) {
-	pollerResp, err := virtualNetworksClient.BeginCreateOrUpdate(
-		ctx,
-		resourceGroupName,
-		virtualNetworkName,
-		armnetwork.VirtualNetwork{
-			Location: to.Ptr(location),
-			Properties: &armnetwork.VirtualNetworkPropertiesFormat{
-				AddressSpace: &armnetwork.AddressSpace{
-					AddressPrefixes: []*string{
-						to.Ptr("fd00:db8:deca::/48"),
-						to.Ptr("10.1.0.0/16"),
-					},
-				},
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+
+	subnets := make([]*armnetwork.Subnet, len(layout.Subnets))
+	for i, s := range layout.Subnets {
+		subnets[i] = &armnetwork.Subnet{
+			Name: to.Ptr(s.Name),
+			Properties: &armnetwork.SubnetPropertiesFormat{
+				AddressPrefixes: to.SliceOfPtrs(s.Prefixes()...),
 			},
-		},
-		nil)
+		}
+	}
 
+	poller, err := client.BeginCreateOrUpdate(ctx, resourceGroup, name, armnetwork.VirtualNetwork{
+		Location: to.Ptr(location),
+		Properties: &armnetwork.VirtualNetworkPropertiesFormat{
+			AddressSpace: &armnetwork.AddressSpace{
+				AddressPrefixes: to.SliceOfPtrs(layout.addressPrefixes()...),
+			},
+			Subnets: subnets,
+		},
+	}, nil)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("starting to create vnet: %w", err)
 	}
 
-	resp, err := pollerResp.PollUntilDone(ctx, nil)
+	resp, err := poller.PollUntilDone(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("creating vnet: %w", err)
+	}
+
+	// guard against things that should be impossible
+	if resp.ID == nil {
+		return nil, fmt.Errorf("vnet id is nil")
+	}
+
+	subnetIds := map[string]string{}
+	if resp.Properties != nil {
+		for _, s := range resp.Properties.Subnets {
+			if s != nil && s.Name != nil && s.ID != nil {
+				subnetIds[*s.Name] = *s.ID
+			}
+		}
 	}
-	return &resp.VirtualNetwork, nil
+	for _, s := range layout.Subnets {
+		if _, ok := subnetIds[s.Name]; !ok {
+			return nil, fmt.Errorf("subnet %s is missing from the created vnet", s.Name)
+		}
+	}
+
+	return &vnet{
+		name:           name,
+		subscriptionId: subscriptionId,
+		resourceGroup:  resourceGroup,
+		id:             *resp.ID,
+		subnetIds:      subnetIds,
+	}, nil
 }
 
-func createSubnet(ctx context.Context) (*armnetwork.Subnet, error) {
-	pollerResp, err := subnetsClient.BeginCreateOrUpdate(
-		ctx,
-		resourceGroupName,
-		virtualNetworkName,
-		subnetName,
-		armnetwork.Subnet{
-			Properties: &armnetwork.SubnetPropertiesFormat{
-				AddressPrefixes: []*string{
-					to.Ptr("fd00:db8:deca:deed::/64"),
-					to.Ptr("10.1.0.0/24"),
-				},
-			},
-		},
-		nil)
+func (v *vnet) GetName() string {
+	return v.name
+}
+
+func (v *vnet) GetId() string {
+	return v.id
+}
 
+// GetSubnetId returns the id of the subnet named name, empty when the vnet has no such subnet
+func (v *vnet) GetSubnetId(name string) string {
+	return v.subnetIds[name]
+}
+
+func (v *vnet) GetSubnetIds() map[string]string {
+	return v.subnetIds
+}
+
+// Returns whether the vnet still exists
+func (v *vnet) Exists(ctx context.Context) (bool, error) {
+	cred, err := GetAzCred()
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("getting az credentials: %w", err)
 	}
 
-	resp, err := pollerResp.PollUntilDone(ctx, nil)
+	client, err := armnetwork.NewVirtualNetworksClient(v.subscriptionId, cred, nil)
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("creating client: %w", err)
+	}
+
+	if _, err := client.Get(ctx, v.resourceGroup, v.name, nil); err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting vnet: %w", err)
 	}
-	return &resp.Subnet, nil
+
+	return true, nil
 }
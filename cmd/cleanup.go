@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+)
+
+func init() {
+	setupRunIdFlag(cleanupCmd)
+	cleanupCmd.MarkFlagRequired(runIdFlag)
+	setupInfraNamesFlag(cleanupCmd)
+	rootCmd.AddCommand(cleanupCmd)
+}
+
+// Cleanup command deletes what provisioning creates outside the resource groups, which delete themselves
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Deletes the app registrations created for a run's infrastructure",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if runId == "" {
+			return errors.New("run id is required")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := infra.Cleanup(cmd.Context(), runId, infraNames); err != nil {
+			return fmt.Errorf("cleaning up infrastructure: %w", err)
+		}
+
+		return nil
+	},
+}
@@ -7,22 +7,30 @@ import (
 )
 
 const (
-	subscriptionIdFlag = "subscription"
-	tenantIdFlag       = "tenant"
-	infraNamesFlag     = "names"
-	infraFileFlag      = "infra-file"
-	infraNameFlag      = "infra-name"
+	subscriptionIdFlag    = "subscription"
+	dnsSubscriptionIdFlag = "dns-subscription"
+	tenantIdFlag          = "tenant"
+	infraNamesFlag        = "names"
+	infraFileFlag         = "infra-file"
+	infraNameFlag         = "infra-name"
+	infraDefsFlag         = "infra-defs"
+	k8sVersionsFlag       = "kubernetes-versions"
+	runIdFlag             = "run-id"
+	resumeFlag            = "resume"
+	planOnlyFlag          = "plan-only"
 )
 
 var (
-	subscriptionId string
-	tenantId       string
+	subscriptionId    string
+	dnsSubscriptionId string
+	tenantId          string
 )
 
 // Saves tenantId and subscriptionId, used in provisioning infrastructure in infra command
 func setupSubTenantFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&subscriptionId, subscriptionIdFlag, "", "subscription")
 	cmd.MarkFlagRequired(subscriptionIdFlag)
+	cmd.Flags().StringVar(&dnsSubscriptionId, dnsSubscriptionIdFlag, "", "subscription for the zone in the separate dns resource group, defaults to --subscription")
 	cmd.Flags().StringVar(&tenantId, tenantIdFlag, "", "tenant")
 	cmd.MarkFlagRequired(tenantIdFlag)
 
@@ -47,6 +55,52 @@ func setupInfraNamesFlag(cmd *cobra.Command) {
 	cmd.Flags().StringArrayVar(&infraNames, infraNamesFlag, []string{}, "infrastructure names to provision, if empty will provision all")
 }
 
+var (
+	infraDefsFile string
+)
+
+// Saves the yaml file infrastructure is defined in, infra.Infras is used when it's empty
+func setupInfraDefsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&infraDefsFile, infraDefsFlag, "", "yaml file defining the infrastructure to use instead of the built in infrastructure")
+}
+
+var (
+	k8sVersions int
+)
+
+// Saves how many of the newest kubernetes versions each infrastructure is expanded into, infrastructure isn't
+// expanded when it's 0
+func setupK8sVersionsFlag(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&k8sVersions, k8sVersionsFlag, 0, "expand each infrastructure into one per each of the newest n kubernetes versions AKS supports, 3 runs N-2 to N")
+}
+
+var (
+	runId string
+)
+
+// Saves the run id resource group and zone names are prefixed with, a random one is generated when it's empty
+func setupRunIdFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&runId, runIdFlag, "", "prefix of every resource group and zone name, up to 16 lowercase letters, digits and dashes. Random when empty")
+}
+
+var (
+	resume bool
+)
+
+// Saves whether provisioning continues from the infrastructure saved in the infra file instead of starting over
+func setupResumeFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&resume, resumeFlag, false, "continue provisioning the infrastructure in --infra-file from the last stage it completed, reusing its run id")
+}
+
+var (
+	planOnly bool
+)
+
+// Saves whether the infra command stops after printing the plan, without provisioning anything
+func setupPlanOnlyFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&planOnly, planOnlyFlag, false, "print the order resources would be provisioned in and exit")
+}
+
 var (
 	infraFile string
 )
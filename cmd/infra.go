@@ -1,9 +1,8 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 
@@ -14,6 +13,11 @@ func init() {
 	setupSubTenantFlags(infraCmd)
 	setupInfraNamesFlag(infraCmd)
 	setupInfraFileFlag(infraCmd)
+	setupInfraDefsFlag(infraCmd)
+	setupK8sVersionsFlag(infraCmd)
+	setupRunIdFlag(infraCmd)
+	setupResumeFlag(infraCmd)
+	setupPlanOnlyFlag(infraCmd)
 	rootCmd.AddCommand(infraCmd)
 }
 
@@ -23,6 +27,18 @@ var infraCmd = &cobra.Command{
 	Short: "Sets up infrastructure for e2e tests",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		infras := infra.Infras
+		if infraDefsFile != "" {
+			var err error
+			if infras, err = infra.LoadDefs(infraDefsFile); err != nil {
+				return fmt.Errorf("loading infrastructure definitions: %w", err)
+			}
+		}
+		if k8sVersions > 0 {
+			var err error
+			if infras, err = infras.ExpandKubernetesVersions(context.Background(), subscriptionId, k8sVersions); err != nil {
+				return fmt.Errorf("expanding kubernetes versions: %w", err)
+			}
+		}
 		if len(infraNames) > 0 {
 			infras = infras.FilterNames(infraNames)
 		}
@@ -31,29 +47,42 @@ var infraCmd = &cobra.Command{
 			return fmt.Errorf("no infrastructure configurations found")
 		}
 
-		provisioned, err := infras.Provision(tenantId, subscriptionId)
-		if err != nil {
-			return fmt.Errorf("provisioning infrastructure: %w", err)
+		// the infra file is rewritten after every stage, a failed run leaves behind the stages it completed
+		var saved []infra.Provisioned
+		if resume {
+			existing, err := readInfraFile(infraFile)
+			if err != nil {
+				return fmt.Errorf("loading infrastructure to resume: %w", err)
+			}
+			if infras, saved, runId, err = infras.Resume(existing, runId); err != nil {
+				return fmt.Errorf("resuming infrastructure: %w", err)
+			}
 		}
 
-		loadable, err := infra.ToLoadable(provisioned)
-		if err != nil {
-			return fmt.Errorf("generating loadable infrastructure: %w", err)
+		if runId == "" {
+			runId = infra.NewRunId()
 		}
-
-		file, err := os.Create(infraFile) // create truncates a file that exists
-		if err != nil {
-			return fmt.Errorf("opening file: %w", err)
+		if err := infra.ValidateRunId(runId); err != nil {
+			return fmt.Errorf("validating run id: %w", err)
 		}
-		defer file.Close()
 
-		bytes, err := json.Marshal(loadable)
+		plan, err := infras.Plan(subscriptionId, dnsSubscriptionId, runId, saved)
 		if err != nil {
-			return fmt.Errorf("marshalling infrastructure: %w", err)
+			return fmt.Errorf("planning infrastructure: %w", err)
+		}
+		fmt.Print(plan)
+		if planOnly {
+			return nil
 		}
 
-		if _, err := file.Write(bytes); err != nil {
-			return fmt.Errorf("writing infrastructure config: %w", err)
+		checkpoint := func(provisioned []infra.Provisioned) error {
+			return writeInfraFile(infraFile, provisioned)
+		}
+		_, timings, err := infras.Provision(tenantId, subscriptionId, dnsSubscriptionId, runId, saved, checkpoint)
+		// the timings show where a failed run spent its time as well
+		fmt.Print(timings)
+		if err != nil {
+			return fmt.Errorf("provisioning infrastructure: %w", err)
 		}
 
 		return nil
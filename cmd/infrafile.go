@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+)
+
+// Reads the infrastructure saved to the infra file at path
+func readInfraFile(path string) ([]infra.Provisioned, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	var loaded []infra.LoadableProvisioned
+	if err := json.Unmarshal(bytes, &loaded); err != nil {
+		return nil, fmt.Errorf("unmarshalling saved infrastructure: %w", err)
+	}
+
+	provisioned, err := infra.ToProvisioned(loaded)
+	if err != nil {
+		return nil, fmt.Errorf("generating provisioned infrastructure: %w", err)
+	}
+
+	return provisioned, nil
+}
+
+// Saves provisioned to the infra file at path. The file is written next to path and renamed over it so a failure
+// part way never leaves a truncated file behind
+func writeInfraFile(path string, provisioned []infra.Provisioned) error {
+	loadable, err := infra.ToLoadable(provisioned)
+	if err != nil {
+		return fmt.Errorf("generating loadable infrastructure: %w", err)
+	}
+
+	bytes, err := json.Marshal(loadable)
+	if err != nil {
+		return fmt.Errorf("marshalling infrastructure: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0644); err != nil {
+		return fmt.Errorf("writing infrastructure config: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replacing infrastructure config: %w", err)
+	}
+
+	return nil
+}
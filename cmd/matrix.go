@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -11,6 +12,10 @@ import (
 
 func init() {
 	setupInfraNamesFlag(matrixCmd)
+	setupInfraDefsFlag(matrixCmd)
+	setupK8sVersionsFlag(matrixCmd)
+	// versions are listed with the subscription, the matrix needs no other azure access
+	matrixCmd.Flags().StringVar(&subscriptionId, subscriptionIdFlag, "", "subscription to list kubernetes versions with, required with --"+k8sVersionsFlag)
 	rootCmd.AddCommand(matrixCmd)
 }
 
@@ -19,6 +24,21 @@ var matrixCmd = &cobra.Command{
 	Short: "Prints the GitHub workflow matrix for the tests",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		infras := infra.Infras
+		if infraDefsFile != "" {
+			var err error
+			if infras, err = infra.LoadDefs(infraDefsFile); err != nil {
+				return fmt.Errorf("loading infrastructure definitions: %w", err)
+			}
+		}
+		if k8sVersions > 0 {
+			if subscriptionId == "" {
+				return fmt.Errorf("--%s is required with --%s", subscriptionIdFlag, k8sVersionsFlag)
+			}
+			var err error
+			if infras, err = infras.ExpandKubernetesVersions(context.Background(), subscriptionId, k8sVersions); err != nil {
+				return fmt.Errorf("expanding kubernetes versions: %w", err)
+			}
+		}
 		if len(infraNames) > 0 {
 			infras = infras.FilterNames(infraNames)
 		}
@@ -2,14 +2,10 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"os"
 
 	"github.com/spf13/cobra"
 
-	"github.com/Azure/azure-provider-external-dns-e2e/infra"
 	"github.com/Azure/azure-provider-external-dns-e2e/logger"
 	"github.com/Azure/azure-provider-external-dns-e2e/suites"
 	"github.com/Azure/azure-provider-external-dns-e2e/tests"
@@ -28,29 +24,20 @@ var testCmd = &cobra.Command{
 		ctx := cmd.Context()
 		lgr := logger.FromContext(ctx)
 
-		file, err := os.Open(infraFile)
+		provisioned, err := readInfraFile(infraFile)
 		if err != nil {
-			return fmt.Errorf("opening file: %w", err)
+			return fmt.Errorf("loading infrastructure: %w", err)
 		}
-		defer file.Close()
 
-		bytes, err := io.ReadAll(file)
-		if err != nil {
-			return fmt.Errorf("reading file: %w", err)
+		if len(provisioned) != 1 {
+			return fmt.Errorf("expected 1 provisioned infrastructure, got %d", len(provisioned))
 		}
-
-		var loaded []infra.LoadableProvisioned
-		if err := json.Unmarshal(bytes, &loaded); err != nil {
-			return fmt.Errorf("unmarshalling saved infrastructure: %w", err)
+		if !provisioned[0].Complete() {
+			return fmt.Errorf("infrastructure %s is only provisioned up to the %q stage, finish it with infra --resume", provisioned[0].Name, provisioned[0].Stage)
 		}
 
-		provisioned, err := infra.ToProvisioned(loaded)
-		if err != nil {
-			return fmt.Errorf("generating provisioned infrastructure: %w", err)
-		}
-
-		if len(provisioned) != 1 {
-			return fmt.Errorf("expected 1 provisioned infrastructure, got %d", len(provisioned))
+		if err := provisioned[0].NewServicePrincipalSecret(ctx); err != nil {
+			return logger.Error(lgr, err)
 		}
 
 		//Should run public and private dns suites one at a time.
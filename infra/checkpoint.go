@@ -0,0 +1,227 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	manifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+)
+
+// Stage is a group of the nodes provisioning an infra. The infra file is rewritten each time a stage completes so a run
+// that fails part way can be resumed from the last completed stage instead of starting over
+type Stage string
+
+const (
+	ResourceGroupStage   Stage = "resource group"
+	ZonesStage           Stage = "zones"
+	VnetStage            Stage = "vnet and link"
+	ClusterStage         Stage = "cluster"
+	IdentitiesStage      Stage = "identities"
+	RoleAssignmentsStage Stage = "role assignments"
+	ExternalDnsStage     Stage = "external dns"
+	NginxStage           Stage = "nginx"
+)
+
+// Stages are in the order they complete in. A stage only completes once the stages before it have, even when its
+// nodes finish first. An infra is fully provisioned once the last one completes
+var Stages = []Stage{
+	ResourceGroupStage,
+	ZonesStage,
+	VnetStage,
+	ClusterStage,
+	IdentitiesStage,
+	RoleAssignmentsStage,
+	ExternalDnsStage,
+	NginxStage,
+}
+
+// Returns whether s is done once completed is, every stage before the last completed one is done too
+func (s Stage) doneBy(completed Stage) bool {
+	return slices.Index(Stages, s) <= slices.Index(Stages, completed)
+}
+
+// Returns whether every stage of p has completed
+func (p Provisioned) Complete() bool {
+	return p.Stage == Stages[len(Stages)-1]
+}
+
+// Matches is to the infrastructure saved by an earlier run so provisioning continues where that run stopped. Each infra
+// takes the Suffix it was saved with so the resources it has yet to create get the names the first run would have
+// given them, infras that weren't saved start from the beginning. The returned states line up with the returned infras.
+// runId has to match the run id saved infrastructure was provisioned with, the saved one is returned when it's empty
+func (is infras) Resume(saved []Provisioned, runId string) (infras, []Provisioned, string, error) {
+	byName := map[string]Provisioned{}
+	for _, p := range saved {
+		if runId == "" {
+			runId = p.RunId
+		}
+		if p.RunId != runId {
+			return nil, nil, "", fmt.Errorf("infrastructure %s was provisioned with run id %s, not %s", p.Name, p.RunId, runId)
+		}
+		byName[p.Name] = p
+	}
+
+	retInfras := make(infras, len(is))
+	states := make([]Provisioned, len(is))
+	for idx, inf := range is {
+		if p, ok := byName[inf.Name]; ok {
+			if p.Suffix == "" {
+				return nil, nil, "", fmt.Errorf("infrastructure %s was saved without a suffix", p.Name)
+			}
+			inf.Suffix = p.Suffix
+			states[idx] = p
+		}
+		retInfras[idx] = inf
+	}
+
+	return retInfras, states, runId, nil
+}
+
+// Returns the last completed stage of p whose resources, and the resources of every stage before it, still exist
+func (p Provisioned) resumeStage(ctx context.Context) (Stage, error) {
+	lgr := logger.FromContext(ctx)
+
+	var ret Stage
+	for _, stage := range Stages {
+		if !stage.doneBy(p.Stage) {
+			break
+		}
+
+		exists, err := p.stageExists(ctx, stage)
+		if err != nil {
+			return "", fmt.Errorf("checking resources of %s stage: %w", stage, err)
+		}
+		if !exists {
+			lgr.Info("resources of completed stage are missing, provisioning them again", "stage", stage)
+			break
+		}
+
+		ret = stage
+	}
+
+	return ret, nil
+}
+
+// Returns whether the resources created by stage exist, looking them up by their saved ids
+func (p Provisioned) stageExists(ctx context.Context, stage Stage) (bool, error) {
+	switch stage {
+	case ResourceGroupStage:
+		if p.ResourceGroup == nil {
+			return false, nil
+		}
+		return p.ResourceGroup.Exists(ctx)
+	case ZonesStage:
+		for _, z := range p.Zones {
+			if _, err := z.GetDnsZone(ctx); err != nil {
+				return found(err)
+			}
+		}
+		for _, pz := range p.PrivateZones {
+			if _, err := pz.GetDnsZone(ctx); err != nil {
+				return found(err)
+			}
+		}
+		return len(p.Zones) > 0 && len(p.PrivateZones) > 0, nil
+	case VnetStage:
+		if p.Vnet == nil {
+			return false, nil
+		}
+		if exists, err := p.Vnet.Exists(ctx); err != nil || !exists {
+			return exists, err
+		}
+		for _, pz := range p.PrivateZones {
+			if linked, err := pz.IsVnetLinked(ctx, linkName, p.Vnet.GetId()); err != nil || !linked {
+				return linked, err
+			}
+		}
+		return true, nil
+	case ClusterStage:
+		if p.Cluster == nil {
+			return false, nil
+		}
+		if _, err := p.Cluster.GetCluster(ctx); err != nil {
+			return found(err)
+		}
+		return true, nil
+	case IdentitiesStage:
+		return p.identitiesExist(ctx)
+	case RoleAssignmentsStage:
+		for _, ra := range p.RoleAssignments {
+			if exists, err := ra.Exists(ctx); err != nil || !exists {
+				return exists, err
+			}
+		}
+		return true, nil
+	case ExternalDnsStage:
+		var objs []client.Object
+		if p.GatewayName != "" {
+			objs = append(objs, clients.NewGatewayResources()...)
+		}
+		if p.DnsEndpointCrd {
+			objs = append(objs, clients.NewDNSEndpointCrdRef())
+		}
+		externalDns, err := externalDnsObjects(p)
+		if err != nil {
+			return false, err
+		}
+		return p.Cluster.HasObjects(ctx, append(objs, externalDns...))
+	case NginxStage:
+		objs, _, _ := nginxObjects(p)
+		if p.IngressServiceName != "" {
+			objs = append(objs, clients.NewIngressNginxResources()...)
+		}
+		return p.Cluster.HasObjects(ctx, objs)
+	}
+
+	return false, fmt.Errorf("unknown stage %s", stage)
+}
+
+// Returns whether the identities external dns authenticates as still exist, along with the federated credentials
+// trusting the external dns service accounts
+func (p Provisioned) identitiesExist(ctx context.Context) (bool, error) {
+	if p.ServicePrincipal != nil {
+		if exists, err := p.ServicePrincipal.Exists(ctx); err != nil || !exists {
+			return exists, err
+		}
+	}
+
+	if p.WorkloadIdentity == nil {
+		return true, nil
+	}
+
+	issuerUrl, dnsConfigs, err := federationConfig(p)
+	if err != nil {
+		return false, err
+	}
+
+	identities := []identity{p.WorkloadIdentity}
+	for _, id := range p.RbacIdentities {
+		identities = append(identities, id)
+	}
+	for _, id := range identities {
+		if exists, err := id.Exists(ctx); err != nil || !exists {
+			return exists, err
+		}
+		for _, dnsConfig := range dnsConfigs {
+			federated, err := id.IsFederated(ctx, issuerUrl, manifests.ExternalDnsNamespace, dnsConfig.ResourceName())
+			if err != nil || !federated {
+				return federated, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// Converts the error of looking up a resource into whether it exists, errors other than not found are returned
+func found(err error) (bool, error) {
+	if clients.IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
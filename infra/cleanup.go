@@ -0,0 +1,65 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+)
+
+// Prefixes of the tags app registrations are created with. Resource groups delete themselves but app registrations live
+// in the tenant, so they're tagged with the run and infra that created them and deleted by Cleanup
+const (
+	runTagPrefix   = "external-dns-e2e-run:"
+	infraTagPrefix = "external-dns-e2e-infra:"
+)
+
+// Returns the tags of the app registration created for the infra named name in the run
+func servicePrincipalTags(runId, name string) []string {
+	return []string{runTagPrefix + runId, infraTagPrefix + name}
+}
+
+// Deletes the app registrations created for the infras named names in the run, or for every infra of the run when names
+// is empty. Every app registration is found by its tags, so ones created by a run that failed before saving the infra
+// file are deleted as well
+func Cleanup(ctx context.Context, runId string, names []string) error {
+	lgr := logger.FromContext(ctx).With("runId", runId)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to clean up infrastructure")
+	defer lgr.Info("finished cleaning up infrastructure")
+
+	sps, err := clients.ListServicePrincipals(ctx, runTagPrefix+runId)
+	if err != nil {
+		return fmt.Errorf("listing service principals: %w", err)
+	}
+
+	// every app registration is attempted even after one fails so a single failure doesn't leave the rest behind
+	var firstErr error
+	for _, sp := range sps {
+		if !ownedBy(sp.GetTags(), names) {
+			continue
+		}
+		if err := sp.Delete(ctx); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("deleting app registration %s: %w", sp.GetId(), err)
+		}
+	}
+
+	return firstErr
+}
+
+// Returns whether tags mark an app registration as created for one of the infras named names, any infra when names is
+// empty
+func ownedBy(tags []string, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if slices.Contains(tags, infraTagPrefix+name) {
+			return true
+		}
+	}
+	return false
+}
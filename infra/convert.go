@@ -11,14 +11,31 @@ import (
 
 // Used to save provisioned infrastructure to .json file, used by ToLoadable() and called from the infra command
 func (p Provisioned) Loadable() (LoadableProvisioned, error) {
-	cluster, err := azure.ParseResourceID(p.Cluster.GetId())
-	if err != nil {
-		return LoadableProvisioned{}, fmt.Errorf("parsing cluster resource id: %w", err)
+	// the cluster and resource group are missing from infrastructure saved before the stages creating them completed
+	var err error
+	var cluster azure.Resource
+	var clusterLocation, clusterDnsServiceIp, clusterPrincipalId, clusterClientId, clusterOidcIssuerUrl string
+	var clusterOptions map[string]struct{}
+	if p.Cluster != nil {
+		cluster, err = azure.ParseResourceID(p.Cluster.GetId())
+		if err != nil {
+			return LoadableProvisioned{}, fmt.Errorf("parsing cluster resource id: %w", err)
+		}
+		clusterLocation = p.Cluster.GetLocation()
+		clusterDnsServiceIp = p.Cluster.GetDnsServiceIp()
+		clusterPrincipalId = p.Cluster.GetPrincipalId()
+		clusterClientId = p.Cluster.GetClientId()
+		clusterOidcIssuerUrl = p.Cluster.GetOidcIssuerUrl()
+		clusterOptions = p.Cluster.GetOptions()
 	}
 
-	resourceGroup, err := arm.ParseResourceID(p.ResourceGroup.GetId())
-	if err != nil {
-		return LoadableProvisioned{}, fmt.Errorf("parsing resource group resource id: %w", err)
+	var resourceGroup arm.ResourceID
+	if p.ResourceGroup != nil {
+		rg, err := arm.ParseResourceID(p.ResourceGroup.GetId())
+		if err != nil {
+			return LoadableProvisioned{}, fmt.Errorf("parsing resource group resource id: %w", err)
+		}
+		resourceGroup = *rg
 	}
 
 	zones := make([]LoadableZone, len(p.Zones))
@@ -42,21 +59,106 @@ func (p Provisioned) Loadable() (LoadableProvisioned, error) {
 		privateZones[i] = z
 	}
 
+	var workloadIdentity azure.Resource
+	var workloadIdentityClientId, workloadIdentityPrincipalId string
+	if p.WorkloadIdentity != nil {
+		workloadIdentity, err = azure.ParseResourceID(p.WorkloadIdentity.GetId())
+		if err != nil {
+			return LoadableProvisioned{}, fmt.Errorf("parsing workload identity resource id: %w", err)
+		}
+		workloadIdentityClientId = p.WorkloadIdentity.GetClientId()
+		workloadIdentityPrincipalId = p.WorkloadIdentity.GetPrincipalId()
+	}
+
+	var vnet azure.Resource
+	var vnetSubnetIds map[string]string
+	if p.Vnet != nil {
+		vnet, err = azure.ParseResourceID(p.Vnet.GetId())
+		if err != nil {
+			return LoadableProvisioned{}, fmt.Errorf("parsing vnet resource id: %w", err)
+		}
+		vnetSubnetIds = p.Vnet.GetSubnetIds()
+	}
+
+	var servicePrincipalAppObjectId, servicePrincipalClientId, servicePrincipalPrincipalId string
+	var servicePrincipalTags []string
+	if p.ServicePrincipal != nil {
+		servicePrincipalAppObjectId = p.ServicePrincipal.GetId()
+		servicePrincipalClientId = p.ServicePrincipal.GetClientId()
+		servicePrincipalPrincipalId = p.ServicePrincipal.GetPrincipalId()
+		servicePrincipalTags = p.ServicePrincipal.GetTags()
+	}
+
+	var rbacIdentities map[DnsAccess]LoadableIdentity
+	for access, id := range p.RbacIdentities {
+		r, err := azure.ParseResourceID(id.GetId())
+		if err != nil {
+			return LoadableProvisioned{}, fmt.Errorf("parsing %s identity resource id: %w", access, err)
+		}
+		if rbacIdentities == nil {
+			rbacIdentities = map[DnsAccess]LoadableIdentity{}
+		}
+		rbacIdentities[access] = LoadableIdentity{
+			ResourceId:  r,
+			ClientId:    id.GetClientId(),
+			PrincipalId: id.GetPrincipalId(),
+		}
+	}
+
+	roleAssignments := make([]LoadableRoleAssignment, len(p.RoleAssignments))
+	for i, ra := range p.RoleAssignments {
+		roleAssignments[i] = LoadableRoleAssignment{
+			Id:          ra.GetId(),
+			PrincipalId: ra.GetPrincipalId(),
+			Role:        ra.GetRoleName(),
+		}
+	}
+
 	return LoadableProvisioned{
-		Name:                p.Name,
-		Cluster:             cluster,
-		ClusterLocation:     p.Cluster.GetLocation(),
-		ClusterDnsServiceIp: p.Cluster.GetDnsServiceIp(),
-		ClusterPrincipalId:  p.Cluster.GetPrincipalId(),
-		ClusterClientId:     p.Cluster.GetClientId(),
-		ClusterOptions:      p.Cluster.GetOptions(),
-		Zones:               zones,
-		PrivateZones:        privateZones,
-		ResourceGroup:       *resourceGroup,
-		SubscriptionId:      p.SubscriptionId,
-		TenantId:            p.TenantId,
-		Ipv4ServiceName:     p.Ipv4ServiceName,
-		Ipv6ServiceName:     p.Ipv6ServiceName,
+		Name:                 p.Name,
+		RunId:                p.RunId,
+		Suffix:               p.Suffix,
+		Stage:                p.Stage,
+		DnsResourceGroup:     p.DnsResourceGroup,
+		Cluster:              cluster,
+		ClusterLocation:      clusterLocation,
+		ClusterDnsServiceIp:  clusterDnsServiceIp,
+		ClusterPrincipalId:   clusterPrincipalId,
+		ClusterClientId:      clusterClientId,
+		ClusterOidcIssuerUrl: clusterOidcIssuerUrl,
+		ClusterOptions:       clusterOptions,
+		Zones:                zones,
+		PrivateZones:         privateZones,
+		ResourceGroup:        resourceGroup,
+		SubscriptionId:       p.SubscriptionId,
+		TenantId:             p.TenantId,
+		Ipv4ServiceName:      p.Ipv4ServiceName,
+		Ipv6ServiceName:      p.Ipv6ServiceName,
+
+		IngressServiceName:         p.IngressServiceName,
+		InternalIngressServiceName: p.InternalIngressServiceName,
+		GatewayName:                p.GatewayName,
+		DnsEndpointCrd:             p.DnsEndpointCrd,
+		Vnet:                       vnet,
+		VnetSubnetIds:              vnetSubnetIds,
+		InternalLbSubnetName:       p.InternalLbSubnetName,
+		InternalLbSubnetPrefixes:   p.InternalLbSubnetPrefixes,
+		UnfilteredZoneName:         p.UnfilteredZoneName,
+		NestedZoneName:             p.NestedZoneName,
+		CentralZoneName:            p.CentralZoneName,
+		ExtraZoneNames:             p.ExtraZoneNames,
+		Providers:                  p.Providers,
+
+		WorkloadIdentity:            workloadIdentity,
+		WorkloadIdentityClientId:    workloadIdentityClientId,
+		WorkloadIdentityPrincipalId: workloadIdentityPrincipalId,
+
+		ServicePrincipalAppObjectId: servicePrincipalAppObjectId,
+		ServicePrincipalClientId:    servicePrincipalClientId,
+		ServicePrincipalPrincipalId: servicePrincipalPrincipalId,
+		ServicePrincipalTags:        servicePrincipalTags,
+		RbacIdentities:              rbacIdentities,
+		RoleAssignments:             roleAssignments,
 	}, nil
 
 }
@@ -99,15 +201,74 @@ func (l LoadableProvisioned) Provisioned() (Provisioned, error) {
 		pzs[i] = clients.LoadPrivateZone(pz)
 	}
 
+	var workloadIdentity identity
+	if l.WorkloadIdentityClientId != "" {
+		workloadIdentity = clients.LoadManagedIdentity(l.WorkloadIdentity, l.WorkloadIdentityClientId, l.WorkloadIdentityPrincipalId)
+	}
+
+	var v vnet
+	if len(l.VnetSubnetIds) > 0 {
+		v = clients.LoadVnet(l.Vnet, l.VnetSubnetIds)
+	}
+
+	var sp servicePrincipal
+	if l.ServicePrincipalClientId != "" {
+		sp = clients.LoadServicePrincipal(l.ServicePrincipalAppObjectId, l.ServicePrincipalClientId, l.ServicePrincipalPrincipalId, l.ServicePrincipalTags)
+	}
+
+	var rbacIdentities map[DnsAccess]identity
+	for access, id := range l.RbacIdentities {
+		if rbacIdentities == nil {
+			rbacIdentities = map[DnsAccess]identity{}
+		}
+		rbacIdentities[access] = clients.LoadManagedIdentity(id.ResourceId, id.ClientId, id.PrincipalId)
+	}
+
+	roleAssignments := make([]roleAssignment, len(l.RoleAssignments))
+	for i, ra := range l.RoleAssignments {
+		roleAssignments[i] = clients.LoadRoleAssignment(ra.Id, ra.PrincipalId, ra.Role)
+	}
+
+	var c cluster
+	if l.Cluster.ResourceName != "" {
+		c = clients.LoadAks(l.Cluster, l.ClusterDnsServiceIp, l.ClusterLocation, l.ClusterPrincipalId, l.ClusterClientId, l.ClusterOidcIssuerUrl, l.ClusterOptions)
+	}
+
+	var rg resourceGroup
+	if l.ResourceGroup.Name != "" {
+		rg = clients.LoadRg(l.ResourceGroup)
+	}
+
 	return Provisioned{
-		Name:            l.Name,
-		Cluster:         clients.LoadAks(l.Cluster, l.ClusterDnsServiceIp, l.ClusterLocation, l.ClusterPrincipalId, l.ClusterClientId, l.ClusterOptions),
-		Zones:           zs,
-		PrivateZones:    pzs,
-		ResourceGroup:   clients.LoadRg(l.ResourceGroup),
-		SubscriptionId:  l.SubscriptionId,
-		TenantId:        l.TenantId,
-		Ipv4ServiceName: l.Ipv4ServiceName,
-		Ipv6ServiceName: l.Ipv6ServiceName,
+		Name:             l.Name,
+		RunId:            l.RunId,
+		Suffix:           l.Suffix,
+		Stage:            l.Stage,
+		DnsResourceGroup: l.DnsResourceGroup,
+		Cluster:          c,
+		Zones:            zs,
+		PrivateZones:     pzs,
+		ResourceGroup:    rg,
+		SubscriptionId:   l.SubscriptionId,
+		TenantId:         l.TenantId,
+		Ipv4ServiceName:  l.Ipv4ServiceName,
+		Ipv6ServiceName:  l.Ipv6ServiceName,
+
+		IngressServiceName:         l.IngressServiceName,
+		InternalIngressServiceName: l.InternalIngressServiceName,
+		GatewayName:                l.GatewayName,
+		DnsEndpointCrd:             l.DnsEndpointCrd,
+		Vnet:                       v,
+		InternalLbSubnetName:       l.InternalLbSubnetName,
+		InternalLbSubnetPrefixes:   l.InternalLbSubnetPrefixes,
+		UnfilteredZoneName:         l.UnfilteredZoneName,
+		NestedZoneName:             l.NestedZoneName,
+		CentralZoneName:            l.CentralZoneName,
+		ExtraZoneNames:             l.ExtraZoneNames,
+		Providers:                  l.Providers,
+		WorkloadIdentity:           workloadIdentity,
+		ServicePrincipal:           sp,
+		RbacIdentities:             rbacIdentities,
+		RoleAssignments:            roleAssignments,
 	}, nil
 }
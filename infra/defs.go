@@ -0,0 +1,240 @@
+package infra
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"sigs.k8s.io/yaml"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	manifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+)
+
+// infraDefs is the format of the file passed to --infra-defs, an alternative to the infrastructure hardcoded in Infras
+type infraDefs struct {
+	Infras []infraDef `json:"infras"`
+}
+
+type infraDef struct {
+	Name string `json:"name"`
+	// Location defaults to the location the hardcoded infrastructure uses
+	Location string `json:"location"`
+	// DnsResourceGroup creates a zone in a separate dns resource group, defaults to true
+	DnsResourceGroup *bool          `json:"dnsResourceGroup"`
+	Cluster          clusterDef     `json:"cluster"`
+	Zones            zonesDef       `json:"zones"`
+	Vnet             *vnetDef       `json:"vnet"`
+	ExternalDns      externalDnsDef `json:"externalDns"`
+	Sources          sourcesDef     `json:"sources"`
+}
+
+// sourcesDef is what gets installed on the cluster for external dns to read hostnames from besides services, each
+// defaults to true
+type sourcesDef struct {
+	// Ingress deploys the public and internal ingress-nginx controllers
+	Ingress *bool `json:"ingress"`
+	// Gateway installs Gateway API with Envoy Gateway for the gateway-httproute source
+	Gateway *bool `json:"gateway"`
+	// Crd installs the DNSEndpoint CRD for the crd source
+	Crd *bool `json:"crd"`
+}
+
+type clusterDef struct {
+	Private bool `json:"private"`
+	// NetworkPlugin is one of the networkPlugins keys, defaults to kubenet
+	NetworkPlugin string `json:"networkPlugin"`
+	// KubernetesVersion pins the cluster to a version, otherwise it gets the location's default or the version
+	// --kubernetes-versions expands it into
+	KubernetesVersion string `json:"kubernetesVersion"`
+	// IpFamilies is one of the ipFamilies keys split on ",", the first family is primary. Defaults to IPv4 and IPv6
+	IpFamilies []string `json:"ipFamilies"`
+}
+
+// zonesDef is how many zones external dns is configured with, zero means one
+type zonesDef struct {
+	Public  int `json:"public"`
+	Private int `json:"private"`
+}
+
+type vnetDef struct {
+	Ipv4AddressSpace string `json:"ipv4AddressSpace"`
+	Ipv6AddressSpace string `json:"ipv6AddressSpace"`
+	// Subnets need one named clients.NodeSubnetName, the internal load balancer tests need one named
+	// clients.InternalLbSubnetName
+	Subnets []subnetDef `json:"subnets"`
+}
+
+type subnetDef struct {
+	Name       string `json:"name"`
+	Ipv4Prefix string `json:"ipv4Prefix"`
+	Ipv6Prefix string `json:"ipv6Prefix"`
+}
+
+type externalDnsDef struct {
+	// Auth is one of the manifests.AuthMode values, defaults to manifests.ManagedIdentityAuth
+	Auth manifests.AuthMode `json:"auth"`
+	// Providers is any of "public" and "private", both are deployed when empty
+	Providers []string `json:"providers"`
+}
+
+// providerNames maps the provider names used in definition files to providers
+var providerNames = map[string]manifests.Provider{
+	"public":  manifests.PublicProvider,
+	"private": manifests.PrivateProvider,
+}
+
+// networkPlugins maps the network plugin names used in definition files to the cluster option selecting them, kubenet
+// is the default and needs no option
+var networkPlugins = map[string]*clients.McOpt{
+	"kubenet":       nil,
+	"azure":         &clients.AzureCniOpt,
+	"azure-overlay": &clients.AzureCniOverlayOpt,
+	"cilium":        &clients.CiliumOpt,
+}
+
+// ipFamilies maps the ip families used in definition files, joined with ",", to the cluster option creating them
+var ipFamilies = map[string]clients.McOpt{
+	"IPv4":      clients.IPv4Opt,
+	"IPv4,IPv6": clients.DualStackOpt,
+	"IPv6,IPv4": clients.IPv6PrimaryOpt,
+}
+
+// Reads infrastructure definitions from the yaml file at path and resolves each into an infra
+func LoadDefs(path string) (infras, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading infrastructure definitions: %w", err)
+	}
+
+	defs := infraDefs{}
+	if err := yaml.UnmarshalStrict(bytes, &defs); err != nil {
+		return nil, fmt.Errorf("unmarshalling infrastructure definitions: %w", err)
+	}
+	if len(defs.Infras) == 0 {
+		return nil, fmt.Errorf("no infrastructure defined in %s", path)
+	}
+
+	ret := make(infras, len(defs.Infras))
+	names := map[string]struct{}{}
+	for idx, def := range defs.Infras {
+		if _, ok := names[def.Name]; ok {
+			return nil, fmt.Errorf("infrastructure %s is defined more than once", def.Name)
+		}
+		names[def.Name] = struct{}{}
+
+		ret[idx], err = def.infra()
+		if err != nil {
+			return nil, fmt.Errorf("resolving infrastructure %q: %w", def.Name, err)
+		}
+	}
+
+	return ret, nil
+}
+
+// Resolves the definition into the cluster options and provisioning settings of an infra
+func (d infraDef) infra() (infra, error) {
+	if d.Name == "" {
+		return infra{}, fmt.Errorf("name is required")
+	}
+
+	ret := infra{
+		Name:             d.Name,
+		Location:         d.Location,
+		Suffix:           uuid.New().String(),
+		DnsResourceGroup: d.DnsResourceGroup == nil || *d.DnsResourceGroup,
+		Ingress:          d.Sources.Ingress == nil || *d.Sources.Ingress,
+		Gateway:          d.Sources.Gateway == nil || *d.Sources.Gateway,
+		DnsEndpoints:     d.Sources.Crd == nil || *d.Sources.Crd,
+	}
+	if ret.Location == "" {
+		ret.Location = location
+	}
+
+	mcOpts, err := d.Cluster.mcOpts()
+	if err != nil {
+		return infra{}, err
+	}
+	ret.McOpts = mcOpts
+
+	if d.Zones.Public < 0 || d.Zones.Private < 0 {
+		return infra{}, fmt.Errorf("zone counts can't be negative")
+	}
+	ret.PublicZones = d.Zones.Public
+	ret.PrivateZones = d.Zones.Private
+
+	if d.Vnet != nil {
+		ret.Vnet = clients.VnetLayout{
+			Ipv4AddressSpace: d.Vnet.Ipv4AddressSpace,
+			Ipv6AddressSpace: d.Vnet.Ipv6AddressSpace,
+		}
+		for _, s := range d.Vnet.Subnets {
+			ret.Vnet.Subnets = append(ret.Vnet.Subnets, clients.SubnetLayout{
+				Name:       s.Name,
+				Ipv4Prefix: s.Ipv4Prefix,
+				Ipv6Prefix: s.Ipv6Prefix,
+			})
+		}
+		if err := ret.Vnet.Validate(); err != nil {
+			return infra{}, fmt.Errorf("invalid vnet: %w", err)
+		}
+	}
+
+	switch d.ExternalDns.Auth {
+	case "", manifests.ManagedIdentityAuth:
+	case manifests.WorkloadIdentityAuth:
+		ret.McOpts = append(ret.McOpts, clients.WorkloadIdentityOpt)
+	case manifests.ServicePrincipalAuth:
+		ret.ServicePrincipal = true
+	default:
+		return infra{}, fmt.Errorf("unknown external dns auth %q", d.ExternalDns.Auth)
+	}
+
+	for _, name := range d.ExternalDns.Providers {
+		provider, ok := providerNames[name]
+		if !ok {
+			return infra{}, fmt.Errorf("unknown external dns provider %q, expected public or private", name)
+		}
+		ret.Providers = append(ret.Providers, provider)
+	}
+
+	return ret, nil
+}
+
+// Resolves the cluster definition into cluster options
+func (c clusterDef) mcOpts() ([]clients.McOpt, error) {
+	var ret []clients.McOpt
+	if c.Private {
+		ret = append(ret, clients.PrivateClusterOpt)
+	}
+
+	if c.NetworkPlugin != "" {
+		opt, ok := networkPlugins[c.NetworkPlugin]
+		if !ok {
+			return nil, fmt.Errorf("unknown network plugin %q, expected kubenet, azure, azure-overlay or cilium", c.NetworkPlugin)
+		}
+		if opt != nil {
+			ret = append(ret, *opt)
+		}
+	}
+
+	if len(c.IpFamilies) > 0 {
+		families := strings.Join(c.IpFamilies, ",")
+		opt, ok := ipFamilies[families]
+		if !ok {
+			return nil, fmt.Errorf("unknown ip families %v, expected [IPv4], [IPv4, IPv6] or [IPv6, IPv4]", c.IpFamilies)
+		}
+		// Azure CNI without overlay picks ipv4 itself and fails to create any other way
+		if c.NetworkPlugin == "azure" && opt.Name != clients.IPv4Opt.Name {
+			return nil, fmt.Errorf("ip families %v are not supported by the azure network plugin, only IPv4 is", c.IpFamilies)
+		}
+		ret = append(ret, opt)
+	}
+
+	if c.KubernetesVersion != "" {
+		ret = append(ret, clients.KubernetesVersionOpt(c.KubernetesVersion))
+	}
+
+	return ret, nil
+}
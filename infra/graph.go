@@ -0,0 +1,350 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+)
+
+// Results of a node, recorded in its NodeTiming
+const (
+	nodeDone    = "done"
+	nodeFailed  = "failed"
+	nodeSkipped = "skipped"
+	nodeNotRun  = "not run"
+)
+
+// node is one resource of an infra. It starts as soon as every node it depends on is done, so resources that don't
+// depend on each other are created in parallel
+type node struct {
+	name string
+	// stage is the checkpoint the node belongs to, nodes of stages an earlier run completed are skipped
+	stage Stage
+	deps  []string
+	// timeout bounds the node's run including the retries its clients make
+	timeout time.Duration
+	// run creates the resource. p is the state of the infra once every node it depends on is done, run returns the
+	// output adding the resource to that state
+	run func(ctx context.Context, p Provisioned) (output, error)
+}
+
+// output adds what a node created to the state of an infra. Outputs are applied one at a time by the executor, never
+// from the goroutine running the node, and replace fields rather than changing them in place
+type output func(p *Provisioned)
+
+// graph is the nodes of an infra in the order they were added
+type graph struct {
+	nodes []node
+}
+
+func (g *graph) add(n node) {
+	g.nodes = append(g.nodes, n)
+}
+
+// Returns the step each node starts at if every node took as long as the others, nodes without dependencies are at
+// step 1. Also returns an error if a node is defined twice, depends on a node that doesn't exist or on itself through
+// other nodes
+func (g graph) steps() (map[string]int, error) {
+	byName := map[string]node{}
+	for _, n := range g.nodes {
+		if _, ok := byName[n.name]; ok {
+			return nil, fmt.Errorf("node %s is defined more than once", n.name)
+		}
+		byName[n.name] = n
+	}
+
+	steps := map[string]int{}
+	visiting := map[string]bool{}
+	var visit func(name string) (int, error)
+	visit = func(name string) (int, error) {
+		if step, ok := steps[name]; ok {
+			return step, nil
+		}
+		if visiting[name] {
+			return 0, fmt.Errorf("node %s depends on itself", name)
+		}
+		visiting[name] = true
+
+		step := 1
+		for _, dep := range byName[name].deps {
+			if _, ok := byName[dep]; !ok {
+				return 0, fmt.Errorf("node %s depends on unknown node %s", name, dep)
+			}
+			depStep, err := visit(dep)
+			if err != nil {
+				return 0, err
+			}
+			step = max(step, depStep+1)
+		}
+
+		steps[name] = step
+		return step, nil
+	}
+
+	for _, n := range g.nodes {
+		if _, err := visit(n.name); err != nil {
+			return nil, err
+		}
+	}
+	return steps, nil
+}
+
+// Returns the nodes in the order they start in, by step and then in the order they were added
+func (g graph) ordered(steps map[string]int) []node {
+	ret := append([]node{}, g.nodes...)
+	sort.SliceStable(ret, func(i, j int) bool { return steps[ret[i].name] < steps[ret[j].name] })
+	return ret
+}
+
+// Returns a table of the nodes of g in the order they start, what they wait on and how long they're given. Nodes of
+// stages completed by the stage is resumed from are marked as skipped
+func (g graph) plan(completed Stage) (string, error) {
+	steps, err := g.steps()
+	if err != nil {
+		return "", err
+	}
+
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tNODE\tSTAGE\tAFTER\tTIMEOUT\t")
+	for _, n := range g.ordered(steps) {
+		after := strings.Join(n.deps, ", ")
+		if after == "" {
+			after = "-"
+		}
+		timeout := n.timeout.String()
+		if n.stage.doneBy(completed) {
+			timeout = nodeSkipped
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t\n", steps[n.name], n.name, n.stage, after, timeout)
+	}
+	w.Flush()
+
+	return b.String(), nil
+}
+
+// nodeResult is sent by the goroutine running a node once it returns
+type nodeResult struct {
+	name       string
+	out        output
+	err        error
+	start, end time.Time
+}
+
+// Runs every node of g whose stage isn't done by state.Stage and returns the state with every output applied, along
+// with how long each node took. state.Stage moves forward as each stage's nodes and the nodes of the stages before it
+// are done, checkpoint is called with the state each time it does. After a node fails no more nodes start, the running
+// ones are waited for since canceling them would leave their resources half created, and the first error is returned
+func (g graph) run(ctx context.Context, state Provisioned, checkpoint func(Provisioned) error) (Provisioned, []NodeTiming, error) {
+	lgr := logger.FromContext(ctx)
+
+	if _, err := g.steps(); err != nil {
+		return state, nil, fmt.Errorf("invalid graph: %w", err)
+	}
+
+	begin := time.Now()
+	timings := map[string]NodeTiming{}
+	waiting := map[string]int{}
+	dependents := map[string][]string{}
+	remaining := map[Stage]int{}
+	byName := map[string]node{}
+	var ready []string
+
+	for _, n := range g.nodes {
+		byName[n.name] = n
+		if n.stage.doneBy(state.Stage) {
+			timings[n.name] = NodeTiming{Infra: state.Name, Node: n.name, Stage: n.stage, Result: nodeSkipped}
+			continue
+		}
+		remaining[n.stage]++
+	}
+	for _, n := range g.nodes {
+		if _, ok := timings[n.name]; ok {
+			continue
+		}
+		for _, dep := range n.deps {
+			if _, skipped := timings[dep]; skipped {
+				continue
+			}
+			waiting[n.name]++
+			dependents[dep] = append(dependents[dep], n.name)
+		}
+		if waiting[n.name] == 0 {
+			ready = append(ready, n.name)
+		}
+	}
+
+	results := make(chan nodeResult)
+	running := 0
+	start := func(name string) {
+		n := byName[name]
+		running++
+		// the snapshot is taken here, on the executor's goroutine, so the node never sees outputs applied while it runs
+		snapshot := state
+		go func() {
+			nodeCtx, cancel := context.WithTimeout(logger.WithContext(ctx, lgr.With("node", n.name)), n.timeout)
+			defer cancel()
+
+			started := time.Now()
+			out, err := n.run(nodeCtx, snapshot)
+			results <- nodeResult{name: n.name, out: out, err: err, start: started, end: time.Now()}
+		}()
+	}
+
+	// moves state.Stage past every stage whose nodes, and the nodes of every stage before it, are done
+	advance := func() error {
+		moved := false
+		for idx := slices.Index(Stages, state.Stage) + 1; idx < len(Stages) && remaining[Stages[idx]] == 0; idx++ {
+			state.Stage = Stages[idx]
+			moved = true
+		}
+		if !moved {
+			return nil
+		}
+
+		lgr.Info("completed stage", "stage", state.Stage)
+		if err := checkpoint(state); err != nil {
+			return fmt.Errorf("saving infrastructure after %s: %w", state.Stage, err)
+		}
+		return nil
+	}
+
+	var firstErr error
+	if err := advance(); err != nil {
+		firstErr = err
+	}
+
+	for {
+		if firstErr == nil {
+			for _, name := range ready {
+				start(name)
+			}
+		}
+		ready = nil
+
+		if running == 0 {
+			break
+		}
+
+		res := <-results
+		running--
+		n := byName[res.name]
+		timing := NodeTiming{
+			Infra:    state.Name,
+			Node:     n.name,
+			Stage:    n.stage,
+			Start:    res.start.Sub(begin),
+			Duration: res.end.Sub(res.start),
+			Result:   nodeDone,
+		}
+
+		if res.err != nil {
+			timing.Result = nodeFailed
+			timings[n.name] = timing
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", n.name, res.err)
+			}
+			continue
+		}
+
+		if res.out != nil {
+			res.out(&state)
+		}
+		timings[n.name] = timing
+		remaining[n.stage]--
+		for _, dependent := range dependents[n.name] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+
+		if firstErr == nil {
+			if err := advance(); err != nil {
+				firstErr = err
+			}
+		}
+	}
+
+	ret := make([]NodeTiming, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		timing, ok := timings[n.name]
+		if !ok {
+			timing = NodeTiming{Infra: state.Name, Node: n.name, Stage: n.stage, Result: nodeNotRun}
+		}
+		ret = append(ret, timing)
+	}
+
+	return state, ret, firstErr
+}
+
+// NodeTiming is when a node of an infra started, relative to the start of provisioning the infra, and how long it ran
+type NodeTiming struct {
+	Infra    string
+	Node     string
+	Stage    Stage
+	Start    time.Duration
+	Duration time.Duration
+	// Result is done, failed, skipped when an earlier run completed the node's stage, or not run when a failure
+	// stopped provisioning before the node started
+	Result string
+}
+
+// Returns whether the node ran, Start and Duration are only set when it did
+func (t NodeTiming) ran() bool {
+	return t.Result == nodeDone || t.Result == nodeFailed
+}
+
+// Timings are the nodes of every infra provisioned together
+type Timings []NodeTiming
+
+// Returns a table of the nodes of each infra in the order they started, with the time the infra took in total
+func (t Timings) String() string {
+	var infraNames []string
+	byInfra := map[string][]NodeTiming{}
+	for _, timing := range t {
+		if _, ok := byInfra[timing.Infra]; !ok {
+			infraNames = append(infraNames, timing.Infra)
+		}
+		byInfra[timing.Infra] = append(byInfra[timing.Infra], timing)
+	}
+
+	b := &strings.Builder{}
+	for _, name := range infraNames {
+		timings := append([]NodeTiming{}, byInfra[name]...)
+		// nodes that never ran have no start, they go last
+		sort.SliceStable(timings, func(i, j int) bool {
+			if timings[i].ran() != timings[j].ran() {
+				return timings[i].ran()
+			}
+			return timings[i].Start < timings[j].Start
+		})
+
+		var total time.Duration
+		for _, timing := range timings {
+			total = max(total, timing.Start+timing.Duration)
+		}
+
+		fmt.Fprintf(b, "timing for %s, %s in total:\n", name, total.Round(time.Second))
+		w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NODE\tSTAGE\tSTART\tDURATION\tRESULT\t")
+		for _, timing := range timings {
+			startCol, durationCol := "-", "-"
+			if timing.ran() {
+				startCol = "+" + timing.Start.Round(time.Second).String()
+				durationCol = timing.Duration.Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", timing.Node, timing.Stage, startCol, durationCol, timing.Result)
+		}
+		w.Flush()
+	}
+
+	return b.String()
+}
@@ -1,33 +1,82 @@
 package infra
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/google/uuid"
 
 	"github.com/Azure/azure-provider-external-dns-e2e/clients"
 )
 
-// Default values used for infrastructure, can be modified if needed
+// Default values used for infrastructure, can be modified if needed. Resource group and zone names are derived from
+// the run id and infra name, see resourceNames
 var (
-	rg              = "externalDns-e2e" + uuid.New().String()
-	location        = "westus"
-	publicZoneName  = "public-zone-" + uuid.NewString()
-	privateZoneName = "private-zone-" + uuid.NewString()
+	location = "westus"
+	// nestedZoneLabel is the label of the child zone delegated from the public zone
+	nestedZoneLabel = "sub"
 )
 
-// Infras is a list of infrastructure configurations the e2e tests will run against
+// Infras is a list of infrastructure configurations the e2e tests will run against. Infras running external dns as a
+// service principal aren't included since creating app registrations needs a Microsoft Graph permission the default
+// credentials don't have, define one with --infra-defs instead. The workload identity cluster is about how external dns
+// authenticates, so it leaves out the ingress controllers, the gateway and the DNSEndpoint CRD
 var Infras = infras{
 	{
-		Name:          "basic cluster",
-		ResourceGroup: rg,
-		Location:      location,
-		Suffix:        uuid.New().String(),
+		Name:             "basic cluster",
+		DnsResourceGroup: true,
+		Location:         location,
+		Ingress:          true,
+		Gateway:          true,
+		DnsEndpoints:     true,
+		Suffix:           uuid.New().String(),
+	},
+	{
+		Name:             "private cluster",
+		DnsResourceGroup: true,
+		Location:         location,
+		Ingress:          true,
+		Gateway:          true,
+		DnsEndpoints:     true,
+		Suffix:           uuid.New().String(),
+		McOpts:           []clients.McOpt{clients.PrivateClusterOpt},
+	},
+	{
+		Name:             "workload identity cluster",
+		DnsResourceGroup: true,
+		Location:         location,
+		Suffix:           uuid.New().String(),
+		McOpts:           []clients.McOpt{clients.WorkloadIdentityOpt},
+	},
+	{
+		Name:             "azure cni cluster",
+		DnsResourceGroup: true,
+		Location:         location,
+		Ingress:          true,
+		Gateway:          true,
+		DnsEndpoints:     true,
+		Suffix:           uuid.New().String(),
+		McOpts:           []clients.McOpt{clients.AzureCniOpt},
 	},
 	{
-		Name:          "private cluster",
-		ResourceGroup: rg,
-		Location:      location,
-		Suffix:        uuid.New().String(),
-		McOpts:        []clients.McOpt{clients.PrivateClusterOpt},
+		Name:             "azure cni overlay cluster",
+		DnsResourceGroup: true,
+		Location:         location,
+		Ingress:          true,
+		Gateway:          true,
+		DnsEndpoints:     true,
+		Suffix:           uuid.New().String(),
+		McOpts:           []clients.McOpt{clients.AzureCniOverlayOpt},
+	},
+	{
+		Name:             "cilium cluster",
+		DnsResourceGroup: true,
+		Location:         location,
+		Ingress:          true,
+		Gateway:          true,
+		DnsEndpoints:     true,
+		Suffix:           uuid.New().String(),
+		McOpts:           []clients.McOpt{clients.CiliumOpt},
 	},
 }
 
@@ -44,3 +93,55 @@ func (i infras) FilterNames(names []string) infras {
 	}
 	return ret
 }
+
+// ExpandKubernetesVersions replaces every infra with one per kubernetes version from N-(count-1) to N, where N is the
+// newest version AKS supports in the infra's location. The version is appended to the name so each shows up in the
+// matrix on its own. Infras already pinned to a version are kept as they are
+func (i infras) ExpandKubernetesVersions(ctx context.Context, subscriptionId string, count int) (infras, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("kubernetes version count must be positive, got %d", count)
+	}
+
+	// versions are listed once per location
+	versions := map[string][]string{}
+	ret := infras{}
+	for _, inf := range i {
+		pinned := false
+		for _, opt := range inf.McOpts {
+			if opt.IsKubernetesVersion() {
+				pinned = true
+				break
+			}
+		}
+		if pinned {
+			ret = append(ret, inf)
+			continue
+		}
+
+		locationVersions, ok := versions[inf.Location]
+		if !ok {
+			var err error
+			if locationVersions, err = clients.ListKubernetesVersions(ctx, subscriptionId, inf.Location); err != nil {
+				return nil, fmt.Errorf("listing kubernetes versions for %s: %w", inf.Name, err)
+			}
+			if len(locationVersions) == 0 {
+				return nil, fmt.Errorf("no kubernetes versions supported in %s", inf.Location)
+			}
+			versions[inf.Location] = locationVersions
+		}
+
+		newest := locationVersions
+		if len(newest) > count {
+			newest = newest[len(newest)-count:]
+		}
+		for _, version := range newest {
+			expanded := inf
+			expanded.Name = inf.Name + " " + version
+			expanded.Suffix = uuid.New().String()
+			expanded.McOpts = append(append([]clients.McOpt{}, inf.McOpts...), clients.KubernetesVersionOpt(version))
+			ret = append(ret, expanded)
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,128 @@
+package infra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxRunIdLength leaves room in a 63 character zone label for the infra name and the zone kind
+	maxRunIdLength = 16
+	// maxSlugLength is how much of the infra name is kept in resource names
+	maxSlugLength = 23
+	// nameHashLength is how many hex characters of the hash of the full infra name follow the slug
+	nameHashLength = 6
+)
+
+var (
+	runIdRegex       = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	nonSlugCharRegex = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Returns a random run id, every resource group and zone name of a run is prefixed with it
+func NewRunId() string {
+	return "run" + strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+}
+
+// Returns an error unless runId can prefix resource group and zone names
+func ValidateRunId(runId string) error {
+	if len(runId) > maxRunIdLength {
+		return fmt.Errorf("run id %q is longer than %d characters", runId, maxRunIdLength)
+	}
+	if !runIdRegex.MatchString(runId) {
+		return fmt.Errorf("run id %q must be lowercase letters, digits and dashes, starting with a letter or digit", runId)
+	}
+	return nil
+}
+
+// resourceNames are the names of an infra's resource groups and zones. Each infra gets its own so infras provisioned in
+// parallel never run external dns instances against the same records, and the run id keeps runs sharing a
+// subscription apart
+type resourceNames struct {
+	ResourceGroup string
+	// DnsResourceGroup is empty when the infra has no zone in a dns resource group
+	DnsResourceGroup string
+	PublicZone       string
+	PrivateZone      string
+	UnfilteredZone   string
+	// CentralZone is created in DnsResourceGroup
+	CentralZone string
+}
+
+// Returns the names of i's resources in the run. The slug only keeps part of the name, a hash of the whole name follows
+// it so infras whose names differ in case, punctuation or past the slug still get their own resources
+func (i infra) resourceNames(runId string) resourceNames {
+	slug := strings.Trim(nonSlugCharRegex.ReplaceAllString(strings.ToLower(i.Name), "-"), "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	hash := sha256.Sum256([]byte(i.Name))
+	prefix := runId + "-" + slug + "-" + hex.EncodeToString(hash[:])[:nameHashLength]
+
+	ret := resourceNames{
+		ResourceGroup:  prefix,
+		PublicZone:     prefix + "-public",
+		PrivateZone:    prefix + "-private",
+		UnfilteredZone: prefix + "-unfiltered",
+	}
+	if i.DnsResourceGroup {
+		ret.DnsResourceGroup = prefix + "-dns"
+		ret.CentralZone = prefix + "-central"
+	}
+	return ret
+}
+
+// Returns the name of the public zone external dns is configured with at position, counting from 1
+func (n resourceNames) publicZone(position int) string {
+	if position == 1 {
+		return n.PublicZone
+	}
+	return fmt.Sprintf("%s-%d", n.PublicZone, position)
+}
+
+// Returns the name of the private zone external dns is configured with at position, counting from 1
+func (n resourceNames) privateZone(position int) string {
+	if position == 1 {
+		return n.PrivateZone
+	}
+	return fmt.Sprintf("%s-%d", n.PrivateZone, position)
+}
+
+// Returns an error if two of is would share a resource group or zone in the run, their external dns instances would
+// fight over the same records. Zones are compared by the domain they're created with, which drops the dashes
+func (is infras) validateNames(runId string) error {
+	owners := map[string]string{}
+	claim := func(name, owner string) error {
+		if other, ok := owners[name]; ok {
+			return fmt.Errorf("infrastructure %s and %s both name a resource %s", other, owner, name)
+		}
+		owners[name] = owner
+		return nil
+	}
+
+	for _, inf := range is {
+		names := inf.resourceNames(runId)
+		for _, rg := range []string{names.ResourceGroup, names.DnsResourceGroup} {
+			if rg == "" {
+				continue
+			}
+			if err := claim(rg, inf.Name); err != nil {
+				return err
+			}
+		}
+		for _, z := range []string{names.PublicZone, names.PrivateZone, names.UnfilteredZone, names.CentralZone} {
+			if z == "" {
+				continue
+			}
+			if err := claim(strings.ReplaceAll(z, "-", "")+".com", inf.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package infra
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResourceNamesUnique(t *testing.T) {
+	longName := "cluster with a name much longer than the slug keeps"
+	cases := []struct {
+		name   string
+		infras infras
+	}{
+		{
+			name:   "names differing in case",
+			infras: infras{{Name: "basic cluster"}, {Name: "Basic Cluster"}},
+		},
+		{
+			name:   "names differing in punctuation",
+			infras: infras{{Name: "basic cluster"}, {Name: "basic-cluster"}, {Name: "basic_cluster!"}},
+		},
+		{
+			name:   "names differing past the slug",
+			infras: infras{{Name: longName + " 1.28"}, {Name: longName + " 1.29"}},
+		},
+		{
+			name:   "expanded kubernetes versions",
+			infras: infras{{Name: "basic cluster 1.28", DnsResourceGroup: true}, {Name: "basic cluster 1.29", DnsResourceGroup: true}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.infras.validateNames("run1"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			seen := map[string]string{}
+			for _, inf := range c.infras {
+				rg := inf.resourceNames("run1").ResourceGroup
+				if other, ok := seen[rg]; ok {
+					t.Fatalf("%q and %q share resource group %s", other, inf.Name, rg)
+				}
+				seen[rg] = inf.Name
+			}
+		})
+	}
+}
+
+func TestValidateNamesDuplicate(t *testing.T) {
+	is := infras{{Name: "basic cluster"}, {Name: "private cluster"}, {Name: "basic cluster"}}
+
+	err := is.validateNames("run1")
+	if err == nil || !strings.Contains(err.Error(), "both name a resource") {
+		t.Fatalf("expected an error about a shared resource, got %v", err)
+	}
+}
+
+func TestResourceNamesFitZoneLabel(t *testing.T) {
+	inf := infra{Name: strings.Repeat("long name ", 10), DnsResourceGroup: true}
+	names := inf.resourceNames(strings.Repeat("r", maxRunIdLength))
+
+	for _, name := range []string{names.ResourceGroup, names.PublicZone, names.PrivateZone, names.UnfilteredZone, names.CentralZone, names.DnsResourceGroup} {
+		if len(name) > 63 {
+			t.Errorf("%s is longer than a 63 character zone label", name)
+		}
+	}
+}
@@ -3,8 +3,13 @@ package infra
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+	"golang.org/x/exp/slices"
 	"golang.org/x/sync/errgroup"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -18,186 +23,613 @@ const (
 	linkName = "sample-link-name"
 )
 
+var (
+	// record types the DNSEndpoint tests publish on top of the A, AAAA and CNAME records external dns manages by default.
+	// Azure private DNS has no NS or CAA record sets
+	publicManagedRecordTypes  = []string{"A", "AAAA", "CNAME", "MX", "SRV", "TXT", "NS", "CAA"}
+	privateManagedRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "SRV", "TXT"}
+)
+
+// servicePrincipalSecretLifetime outlives the resource groups by an hour so a secret stays valid for the tests that
+// run after provisioning
+const servicePrincipalSecretLifetime = 5 * time.Hour
+
+// Names of the nodes other nodes depend on
+const (
+	resourceGroupNode    = "resource group"
+	dnsResourceGroupNode = "dns resource group"
+	publicZoneNode       = "public zone"
+	zonesNode            = "zones"
+	vnetNode             = "vnet"
+	clusterNode          = "cluster"
+	workloadIdentityNode = "workload identity"
+	rbacIdentitiesNode   = "rbac identities"
+	servicePrincipalNode = "service principal"
+	roleAssignmentsNode  = "role assignments"
+	gatewayNode          = "gateway"
+	dnsEndpointCrdNode   = "dnsendpoint crd"
+	externalDnsNode      = "external dns"
+	nginxNode            = "nginx"
+)
+
 // Provisions all infrastructure needed to run e2e tests: resource group, managed cluster, dns zones, and a vnet
-// Also deploys external dns and two nginx services needed for testing
-func (i *infra) Provision(ctx context.Context, tenantId, subscriptionId string) (Provisioned, *logger.LoggedError) {
-	lgr := logger.FromContext(ctx).With("infra", i.Name)
+// Also deploys external dns and two nginx services needed for testing. The zone in the dns resource group is created in
+// dnsSubscriptionId, which defaults to subscriptionId when empty. Resource group and zone names are prefixed with runId.
+// Provisioning continues after the last completed stage of saved, which is empty for infrastructure not provisioned
+// before. checkpoint is called with the state of the infrastructure each time a stage completes. The timing of every
+// node is returned even when provisioning fails
+func (i *infra) Provision(ctx context.Context, tenantId, subscriptionId, dnsSubscriptionId, runId string, saved Provisioned, checkpoint func(Provisioned) error) (Provisioned, []NodeTiming, *logger.LoggedError) {
+	lgr := logger.FromContext(ctx).With("infra", i.Name, "runId", runId)
 	lgr.Info("provisioning infrastructure")
 	defer lgr.Info("finished provisioning infrastructure")
 
-	ret := Provisioned{
-		Name:           i.Name,
-		SubscriptionId: subscriptionId,
-		TenantId:       tenantId,
-	}
+	ret := saved
+	if ret.Stage != "" {
+		stage, err := ret.resumeStage(ctx)
+		if err != nil {
+			return ret, nil, logger.Error(lgr, fmt.Errorf("finding stage to resume from: %w", err))
+		}
+		lgr.Info("resuming provisioning", "completedStage", stage)
+		ret.Stage = stage
 
-	var err error
-	ret.ResourceGroup, err = clients.NewResourceGroup(ctx, subscriptionId, i.ResourceGroup, i.Location, clients.DeleteAfterOpt(4*time.Hour))
+		// the saved service principal has no secret, external dns needs one if it's deployed again
+		if IdentitiesStage.doneBy(ret.Stage) {
+			if err := ret.NewServicePrincipalSecret(ctx); err != nil {
+				return ret, nil, logger.Error(lgr, err)
+			}
+		}
+	}
+	ret.Name = i.Name
+	ret.RunId = runId
+	ret.Suffix = i.Suffix
+	ret.SubscriptionId = subscriptionId
+	ret.TenantId = tenantId
+	ret.DnsResourceGroup = i.resourceNames(runId).DnsResourceGroup
+	ret.Providers = i.Providers
 
+	ret, timings, err := i.graph(subscriptionId, dnsSubscriptionId, runId).run(logger.WithContext(ctx, lgr), ret, checkpoint)
 	if err != nil {
-		return Provisioned{}, logger.Error(lgr, fmt.Errorf("creating resource group %s: %w", i.ResourceGroup, err))
+		return ret, timings, logger.Error(lgr, err)
 	}
 
-	// create resources
-	var resEg errgroup.Group
+	return ret, timings, nil
+}
 
-	var subnetId string
-	var vnetId string
+// Returns the plan of the nodes provisioning i, resuming after the completed stage
+func (i infra) plan(subscriptionId, dnsSubscriptionId, runId string, completed Stage) (string, error) {
+	return i.graph(subscriptionId, dnsSubscriptionId, runId).plan(completed)
+}
 
-	resEg.Go(func() error {
-		zone, err := clients.NewZone(ctx, subscriptionId, i.ResourceGroup, publicZoneName)
-		if err != nil {
-			return logger.Error(lgr, fmt.Errorf("creating zone: %w", err))
-		}
-		ret.Zones = append(ret.Zones, zone)
-		return nil
+// Returns the nodes i is provisioned with. Commands run on the cluster one after another rather than relying on the
+// run command api taking several at once, so every node deploying onto the cluster waits on the one before it
+func (i infra) graph(subscriptionId, dnsSubscriptionId, runId string) graph {
+	names := i.resourceNames(runId)
+	if dnsSubscriptionId == "" {
+		dnsSubscriptionId = subscriptionId
+	}
+
+	g := graph{}
+	g.add(node{
+		name:    resourceGroupNode,
+		stage:   ResourceGroupStage,
+		timeout: 5 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			rg, err := clients.NewResourceGroup(ctx, subscriptionId, names.ResourceGroup, i.Location, clients.DeleteAfterOpt(4*time.Hour))
+			if err != nil {
+				return nil, fmt.Errorf("creating resource group %s: %w", names.ResourceGroup, err)
+			}
+			return func(p *Provisioned) { p.ResourceGroup = rg }, nil
+		},
 	})
 
-	resEg.Go(func() error {
-		privateZone, err := clients.NewPrivateZone(ctx, subscriptionId, i.ResourceGroup, privateZoneName)
-		if err != nil {
-			return logger.Error(lgr, fmt.Errorf("creating private zone: %w", err))
-		}
-		ret.PrivateZones = append(ret.PrivateZones, privateZone)
-		return nil
+	i.addZoneNodes(&g, names, subscriptionId, dnsSubscriptionId)
+
+	layout := i.vnetLayout()
+	g.add(node{
+		name:    vnetNode,
+		stage:   VnetStage,
+		deps:    []string{resourceGroupNode},
+		timeout: 10 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			v, err := clients.NewVnet(ctx, subscriptionId, names.ResourceGroup, "vnet"+i.Suffix, i.Location, layout)
+			if err != nil {
+				return nil, fmt.Errorf("creating vnet: %w", err)
+			}
+
+			return func(p *Provisioned) {
+				p.Vnet = v
+				p.InternalLbSubnetName = ""
+				p.InternalLbSubnetPrefixes = nil
+				if subnet, ok := layout.Subnet(clients.InternalLbSubnetName); ok {
+					p.InternalLbSubnetName = subnet.Name
+					p.InternalLbSubnetPrefixes = subnet.Prefixes()
+				}
+			}, nil
+		},
+	})
+
+	g.add(node{
+		name:    "vnet links",
+		stage:   VnetStage,
+		deps:    []string{vnetNode, zonesNode},
+		timeout: 10 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			for _, pz := range p.PrivateZones {
+				if err := pz.LinkVnet(ctx, linkName, p.Vnet.GetId()); err != nil {
+					return nil, fmt.Errorf("creating vnet link: %w", err)
+				}
+			}
+			return nil, nil
+		},
+	})
+
+	g.add(node{
+		name:    clusterNode,
+		stage:   ClusterStage,
+		deps:    []string{vnetNode},
+		timeout: 30 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			c, err := clients.NewAks(ctx, subscriptionId, names.ResourceGroup, "cluster"+i.Suffix, i.Location, p.Vnet.GetSubnetId(clients.NodeSubnetName), i.McOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("creating managed cluster: %w", err)
+			}
+			return func(p *Provisioned) { p.Cluster = c }, nil
+		},
 	})
 
-	if err := resEg.Wait(); err != nil {
-		return Provisioned{}, logger.Error(lgr, err)
+	identityNodes := i.addIdentityNodes(&g, names, runId)
+
+	g.add(node{
+		name:    roleAssignmentsNode,
+		stage:   RoleAssignmentsStage,
+		deps:    append([]string{zonesNode, vnetNode, clusterNode}, identityNodes...),
+		timeout: 15 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			assignments, err := assignRoles(ctx, p)
+			if err != nil {
+				return nil, err
+			}
+			return func(p *Provisioned) { p.RoleAssignments = assignments }, nil
+		},
+	})
+
+	// the sources external dns reads are installed first, one after another, so it starts with every source it watches
+	lastSource := clusterNode
+
+	// Gateway API CRDs have to exist before external dns starts watching routes
+	if i.Gateway {
+		g.add(node{
+			name:    gatewayNode,
+			stage:   ExternalDnsStage,
+			deps:    []string{lastSource},
+			timeout: 15 * time.Minute,
+			run: func(ctx context.Context, p Provisioned) (output, error) {
+				if err := deployGateway(ctx, p); err != nil {
+					return nil, fmt.Errorf("error deploying gateway onto cluster %w", err)
+				}
+				return func(p *Provisioned) { p.GatewayName = clients.GatewayName }, nil
+			},
+		})
+		lastSource = gatewayNode
 	}
 
-	//create vnet and link
-	resEg.Go(func() error {
-		vnetId, subnetId, err = clients.NewVnet(ctx, subscriptionId, i.ResourceGroup, i.Location, ret.PrivateZones[0].GetName())
+	// the crd source fails to start without the DNSEndpoint CRD
+	if i.DnsEndpoints {
+		g.add(node{
+			name:    dnsEndpointCrdNode,
+			stage:   ExternalDnsStage,
+			deps:    []string{lastSource},
+			timeout: 10 * time.Minute,
+			run: func(ctx context.Context, p Provisioned) (output, error) {
+				if err := p.Cluster.ApplyUrl(ctx, clients.DNSEndpointCrdUrl); err != nil {
+					return nil, fmt.Errorf("error installing DNSEndpoint CRD onto cluster %w", err)
+				}
+				return func(p *Provisioned) { p.DnsEndpointCrd = true }, nil
+			},
+		})
+		lastSource = dnsEndpointCrdNode
+	}
+
+	g.add(node{
+		name:    externalDnsNode,
+		stage:   ExternalDnsStage,
+		deps:    []string{lastSource, roleAssignmentsNode},
+		timeout: 15 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			if err := DeployExternalDNS(ctx, p); err != nil {
+				return nil, fmt.Errorf("error deploying external dns onto cluster %w", err)
+			}
+			return nil, nil
+		},
+	})
+
+	g.add(node{
+		name:    nginxNode,
+		stage:   NginxStage,
+		deps:    []string{externalDnsNode},
+		timeout: 15 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			ipv4Service, ipv6Service, err := deployNginx(ctx, p)
+			if err != nil {
+				return nil, fmt.Errorf("error deploying nginx onto cluster %w", err)
+			}
+
+			return func(p *Provisioned) {
+				p.Ipv4ServiceName = ipv4Service.Name
+				p.Ipv6ServiceName = ""
+				if ipv6Service != nil {
+					p.Ipv6ServiceName = ipv6Service.Name
+				}
+			}, nil
+		},
+	})
+
+	if i.Ingress {
+		g.add(node{
+			name:    "ingress nginx",
+			stage:   NginxStage,
+			deps:    []string{nginxNode},
+			timeout: 15 * time.Minute,
+			run: func(ctx context.Context, p Provisioned) (output, error) {
+				if err := deployIngressNginx(ctx, p); err != nil {
+					return nil, fmt.Errorf("error deploying ingress controllers onto cluster %w", err)
+				}
+
+				return func(p *Provisioned) {
+					p.IngressServiceName = clients.IngressControllerServiceName(clients.PublicIngressClass)
+					p.InternalIngressServiceName = clients.IngressControllerServiceName(clients.InternalIngressClass)
+				}, nil
+			},
+		})
+	}
+
+	return g
+}
+
+// zoneSet collects the zones of an infra as their nodes complete, the zones node adds them to the state together so
+// their order doesn't depend on which finished first
+type zoneSet struct {
+	public, unfiltered, central, nested zone
+	private                             privateZone
+	// extra and extraPrivate are indexed by position, starting from the second zone of each kind
+	extra        []zone
+	extraPrivate []privateZone
+}
+
+// Adds a node for every zone of i, and the zones node depending on all of them
+func (i infra) addZoneNodes(g *graph, names resourceNames, subscriptionId, dnsSubscriptionId string) {
+	zones := &zoneSet{
+		extra:        make([]zone, max(i.PublicZones-1, 0)),
+		extraPrivate: make([]privateZone, max(i.PrivateZones-1, 0)),
+	}
+	zoneNodes := []string{publicZoneNode}
+
+	// creates the public zone named name and returns the output recording it with set
+	newZone := func(ctx context.Context, resourceGroup, name string, set func(z zone)) (output, error) {
+		z, err := clients.NewZone(ctx, subscriptionId, resourceGroup, name)
 		if err != nil {
-			return logger.Error(lgr, fmt.Errorf("creating vnet: %w", err))
+			return nil, fmt.Errorf("creating zone %s: %w", name, err)
 		}
-
-		err = ret.PrivateZones[0].LinkVnet(ctx, linkName, vnetId)
+		return func(*Provisioned) { set(z) }, nil
+	}
+	newPrivateZone := func(ctx context.Context, name string, set func(z privateZone)) (output, error) {
+		z, err := clients.NewPrivateZone(ctx, subscriptionId, names.ResourceGroup, name)
 		if err != nil {
-			return logger.Error(lgr, fmt.Errorf("creating vnet link: %w", err))
+			return nil, fmt.Errorf("creating private zone %s: %w", name, err)
 		}
-		return nil
+		return func(*Provisioned) { set(z) }, nil
+	}
+
+	g.add(node{
+		name:    publicZoneNode,
+		stage:   ZonesStage,
+		deps:    []string{resourceGroupNode},
+		timeout: 5 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			return newZone(ctx, names.ResourceGroup, names.publicZone(1), func(z zone) { zones.public = z })
+		},
 	})
 
-	if err := resEg.Wait(); err != nil {
-		return Provisioned{}, logger.Error(lgr, err)
+	// a child zone of the public zone, external dns should write records under it there rather than in the parent
+	zoneNodes = append(zoneNodes, "nested zone")
+	g.add(node{
+		name:    "nested zone",
+		stage:   ZonesStage,
+		deps:    []string{publicZoneNode},
+		timeout: 5 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			z, err := clients.NewChildZone(ctx, subscriptionId, names.ResourceGroup, zones.public.GetName(), nestedZoneLabel)
+			if err != nil {
+				return nil, fmt.Errorf("creating nested zone: %w", err)
+			}
+			return func(*Provisioned) { zones.nested = z }, nil
+		},
+	})
+
+	zoneNodes = append(zoneNodes, "private zone")
+	g.add(node{
+		name:    "private zone",
+		stage:   ZonesStage,
+		deps:    []string{resourceGroupNode},
+		timeout: 5 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			return newPrivateZone(ctx, names.privateZone(1), func(z privateZone) { zones.private = z })
+		},
+	})
+
+	for idx := range zones.extra {
+		func(idx int) {
+			name := fmt.Sprintf("public zone %d", idx+2)
+			zoneNodes = append(zoneNodes, name)
+			g.add(node{
+				name:    name,
+				stage:   ZonesStage,
+				deps:    []string{resourceGroupNode},
+				timeout: 5 * time.Minute,
+				run: func(ctx context.Context, p Provisioned) (output, error) {
+					return newZone(ctx, names.ResourceGroup, names.publicZone(idx+2), func(z zone) { zones.extra[idx] = z })
+				},
+			})
+		}(idx)
+	}
+	for idx := range zones.extraPrivate {
+		func(idx int) {
+			name := fmt.Sprintf("private zone %d", idx+2)
+			zoneNodes = append(zoneNodes, name)
+			g.add(node{
+				name:    name,
+				stage:   ZonesStage,
+				deps:    []string{resourceGroupNode},
+				timeout: 5 * time.Minute,
+				run: func(ctx context.Context, p Provisioned) (output, error) {
+					return newPrivateZone(ctx, names.privateZone(idx+2), func(z privateZone) { zones.extraPrivate[idx] = z })
+				},
+			})
+		}(idx)
 	}
 
-	resEg.Go(func() error {
-		ret.Cluster, err = clients.NewAks(ctx, subscriptionId, i.ResourceGroup, "cluster"+i.Suffix, i.Location, subnetId, i.McOpts...)
+	// a zone external dns can write to but is left out of the domain filter
+	zoneNodes = append(zoneNodes, "unfiltered zone")
+	g.add(node{
+		name:    "unfiltered zone",
+		stage:   ZonesStage,
+		deps:    []string{resourceGroupNode},
+		timeout: 5 * time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			return newZone(ctx, names.ResourceGroup, names.UnfilteredZone, func(z zone) { zones.unfiltered = z })
+		},
+	})
 
-		if err != nil {
-			return logger.Error(lgr, fmt.Errorf("creating managed cluster: %w", err))
-		}
+	// a zone in its own resource group needs a separate external dns instance
+	if names.DnsResourceGroup != "" {
+		g.add(node{
+			name:    dnsResourceGroupNode,
+			stage:   ZonesStage,
+			timeout: 5 * time.Minute,
+			run: func(ctx context.Context, p Provisioned) (output, error) {
+				if _, err := clients.NewResourceGroup(ctx, dnsSubscriptionId, names.DnsResourceGroup, i.Location, clients.DeleteAfterOpt(4*time.Hour)); err != nil {
+					return nil, fmt.Errorf("creating dns resource group %s: %w", names.DnsResourceGroup, err)
+				}
+				return nil, nil
+			},
+		})
 
-		return nil
+		zoneNodes = append(zoneNodes, "central zone")
+		g.add(node{
+			name:    "central zone",
+			stage:   ZonesStage,
+			deps:    []string{dnsResourceGroupNode},
+			timeout: 5 * time.Minute,
+			run: func(ctx context.Context, p Provisioned) (output, error) {
+				z, err := clients.NewZone(ctx, dnsSubscriptionId, names.DnsResourceGroup, names.CentralZone)
+				if err != nil {
+					return nil, fmt.Errorf("creating zone in dns resource group: %w", err)
+				}
+				return func(*Provisioned) { zones.central = z }, nil
+			},
+		})
+	}
+
+	g.add(node{
+		name:    zonesNode,
+		stage:   ZonesStage,
+		deps:    zoneNodes,
+		timeout: time.Minute,
+		run: func(ctx context.Context, p Provisioned) (output, error) {
+			return zones.output, nil
+		},
 	})
+}
 
-	if err := resEg.Wait(); err != nil {
-		return Provisioned{}, logger.Error(lgr, err)
+// Adds every zone of the set to p, the filtered public zone first
+func (z *zoneSet) output(p *Provisioned) {
+	p.Zones = []zone{z.public, z.unfiltered}
+	p.UnfilteredZoneName = z.unfiltered.GetName()
+	p.CentralZoneName = ""
+	if z.central != nil {
+		p.Zones = append(p.Zones, z.central)
+		p.CentralZoneName = z.central.GetName()
+	}
+	p.Zones = append(p.Zones, z.nested)
+	p.NestedZoneName = z.nested.GetName()
+	p.ExtraZoneNames = nil
+	for _, extra := range z.extra {
+		p.Zones = append(p.Zones, extra)
+		p.ExtraZoneNames = append(p.ExtraZoneNames, extra.GetName())
 	}
+	p.PrivateZones = append([]privateZone{z.private}, z.extraPrivate...)
+}
 
-	//setting permissions for private zones
+// Adds the nodes creating the identities external dns authenticates as, when it doesn't use the kubelet identity,
+// and returns their names
+func (i infra) addIdentityNodes(g *graph, names resourceNames, runId string) []string {
+	var ret []string
+
+	// with workload identity external dns gets its own identity instead of using the kubelet identity, and the rbac
+	// tests get identities holding less than it
+	if slices.ContainsFunc(i.McOpts, func(opt clients.McOpt) bool { return opt.Name == clients.WorkloadIdentityOpt.Name }) {
+		name := "externaldns" + i.Suffix
+
+		ret = append(ret, workloadIdentityNode)
+		g.add(node{
+			name:    workloadIdentityNode,
+			stage:   IdentitiesStage,
+			deps:    []string{clusterNode, zonesNode},
+			timeout: 10 * time.Minute,
+			run: func(ctx context.Context, p Provisioned) (output, error) {
+				issuerUrl, dnsConfigs, err := federationConfig(p)
+				if err != nil {
+					return nil, err
+				}
+
+				identity, err := newFederatedIdentity(ctx, p.SubscriptionId, names.ResourceGroup, name, i.Location, issuerUrl, dnsConfigs)
+				if err != nil {
+					return nil, fmt.Errorf("provisioning workload identity: %w", err)
+				}
+				return func(p *Provisioned) { p.WorkloadIdentity = identity }, nil
+			},
+		})
+
+		ret = append(ret, rbacIdentitiesNode)
+		g.add(node{
+			name:    rbacIdentitiesNode,
+			stage:   IdentitiesStage,
+			deps:    []string{clusterNode, zonesNode},
+			timeout: 10 * time.Minute,
+			run: func(ctx context.Context, p Provisioned) (output, error) {
+				issuerUrl, dnsConfigs, err := federationConfig(p)
+				if err != nil {
+					return nil, err
+				}
+
+				rbacIdentities, err := newRbacIdentities(ctx, p.SubscriptionId, names.ResourceGroup, name, i.Location, issuerUrl, dnsConfigs)
+				if err != nil {
+					return nil, fmt.Errorf("provisioning rbac identities: %w", err)
+				}
+				return func(p *Provisioned) { p.RbacIdentities = rbacIdentities }, nil
+			},
+		})
+	}
+
+	if i.ServicePrincipal {
+		ret = append(ret, servicePrincipalNode)
+		g.add(node{
+			name:    servicePrincipalNode,
+			stage:   IdentitiesStage,
+			deps:    []string{resourceGroupNode},
+			timeout: 10 * time.Minute,
+			run: func(ctx context.Context, p Provisioned) (output, error) {
+				sp, err := clients.NewServicePrincipal(ctx, names.ResourceGroup+"-externaldns", servicePrincipalTags(runId, i.Name), servicePrincipalSecretLifetime)
+				if err != nil {
+					return nil, fmt.Errorf("creating service principal: %w", err)
+				}
+				return func(p *Provisioned) { p.ServicePrincipal = sp }, nil
+			},
+		})
+	}
+
+	return ret
+}
+
+// Grants the identity external dns authenticates as the dns contributor roles on every zone, the cluster identity
+// network contributor on the vnet and the rbac identities their roles. Returns every assignment created
+func assignRoles(ctx context.Context, p Provisioned) ([]roleAssignment, error) {
 	var permEg errgroup.Group
-	for _, pz := range ret.PrivateZones {
+	roles := &roleAssigner{}
+
+	//setting permissions for private zones
+	for _, pz := range p.PrivateZones {
 		func(pz privateZone) {
 			permEg.Go(func() error {
 				dns, err := pz.GetDnsZone(ctx)
 				if err != nil {
-					return logger.Error(lgr, fmt.Errorf("getting dns: %w", err))
-				}
-
-				principalId := ret.Cluster.GetPrincipalId()
-				role := clients.PrivateDnsContributorRole
-				if _, err := clients.NewRoleAssignment(ctx, subscriptionId, *dns.ID, principalId, role); err != nil {
-					return logger.Error(lgr, fmt.Errorf("creating %s role assignment: %w", role.Name, err))
+					return fmt.Errorf("getting dns: %w", err)
 				}
 
-				return nil
+				return roles.assign(ctx, p.SubscriptionId, *dns.ID, p.dnsPrincipalId(), clients.PrivateDnsContributorRole)
 			})
 		}(pz)
 	}
 
 	//setting permissions for public zones
-	for _, z := range ret.Zones {
+	for _, z := range p.Zones {
 		func(z zone) {
 			permEg.Go(func() error {
 				dns, err := z.GetDnsZone(ctx)
 				if err != nil {
-					return logger.Error(lgr, fmt.Errorf("getting dns: %w", err))
-				}
-
-				principalId := ret.Cluster.GetPrincipalId()
-				role := clients.DnsContributorRole
-				if _, err := clients.NewRoleAssignment(ctx, subscriptionId, *dns.ID, principalId, role); err != nil {
-					return logger.Error(lgr, fmt.Errorf("creating %s role assignment: %w", role.Name, err))
+					return fmt.Errorf("getting dns: %w", err)
 				}
 
-				return nil
+				// zones in the dns resource group can be in another subscription
+				return roles.assign(ctx, z.GetSubscriptionId(), *dns.ID, p.dnsPrincipalId(), clients.DnsContributorRole)
 			})
 		}(z)
 	}
 
 	permEg.Go(func() error {
-		principalId := ret.Cluster.GetPrincipalId()
+		principalId := p.Cluster.GetPrincipalId()
 
-		if vnetId == "" || subnetId == "" {
-			return logger.Error(lgr, fmt.Errorf("vnet id is empty before role assignment"))
+		if p.Vnet == nil {
+			return fmt.Errorf("vnet is nil before role assignment")
 		}
 
-		//Adding network contributor role on the vnet
-		role := clients.NetworkContributorRole
-		if _, err := clients.NewRoleAssignment(ctx, subscriptionId, vnetId, principalId, role); err != nil {
-			return logger.Error(lgr, fmt.Errorf("creating %s role assignment: %w", role.Name, err))
-		}
+		// Adding network contributor role on the vnet. Role assignments are inherited by child resources so this covers
+		// the node subnet and the internal load balancer subnet, a separate grant on a subnet would add nothing
+		return roles.assign(ctx, p.SubscriptionId, p.Vnet.GetId(), principalId, clients.NetworkContributorRole)
+	})
 
-		//Adding network contributor role on the subnet
-		if _, err := clients.NewRoleAssignment(ctx, subscriptionId, subnetId, principalId, role); err != nil {
-			return logger.Error(lgr, fmt.Errorf("creating %s role assignment: %w", role.Name, err))
-		}
-		return nil
+	permEg.Go(func() error {
+		return assignRbacRoles(ctx, p, roles)
 	})
 
 	if err := permEg.Wait(); err != nil {
-		return Provisioned{}, logger.Error(lgr, err)
-	}
-
-	//Deploy external dns
-	err = deployExternalDNS(ctx, ret)
-	if err != nil {
-		return ret, logger.Error(lgr, fmt.Errorf("error deploying external dns onto cluster %w", err))
-	}
-
-	ipv4Service, ipv6Service, err := deployNginx(ctx, ret)
-	if err != nil {
-		return ret, logger.Error(lgr, fmt.Errorf("error deploying nginx onto cluster %w", err))
+		return nil, err
 	}
 
-	ret.Ipv4ServiceName = ipv4Service.Name
-	ret.Ipv6ServiceName = ipv6Service.Name
-
-	return ret, nil
+	return roles.created(), nil
 }
 
-// Calls Provision function above on every type of infra specified in command line
-func (is infras) Provision(tenantId, subscriptionId string) ([]Provisioned, error) {
+// Calls Provision function above on every type of infra specified in command line, naming their resources after runId.
+// saved is the state each infra continues from, lined up with is, and can be nil when nothing was provisioned before.
+// checkpoint is called with the latest state of every infra each time one of them completes a stage, never concurrently.
+// The timing of every node of every infra is returned even when provisioning fails. Nothing is provisioned when two
+// infras would share a resource group or zone
+func (is infras) Provision(tenantId, subscriptionId, dnsSubscriptionId, runId string, saved []Provisioned, checkpoint func([]Provisioned) error) ([]Provisioned, Timings, error) {
 	lgr := logger.FromContext(context.Background())
 
 	lgr.Info("starting to provision all infrastructure")
 	defer lgr.Info("finished provisioning all infrastructure")
 
+	if err := is.validateNames(runId); err != nil {
+		return nil, nil, err
+	}
+
 	var eg errgroup.Group
+	var mu sync.Mutex
 	provisioned := make([]Provisioned, len(is))
+	for idx := range is {
+		provisioned[idx] = is.saved(saved, idx, runId)
+	}
+	timings := make([][]NodeTiming, len(is))
 
 	for idx, inf := range is {
-		func(idx int, inf infra) {
+		func(idx int, inf infra, start Provisioned) {
 			eg.Go(func() error {
 				ctx := context.Background()
 				lgr := logger.FromContext(ctx)
 				ctx = logger.WithContext(ctx, lgr.With("infra", inf.Name))
 
-				provisionedInfra, err := inf.Provision(ctx, tenantId, subscriptionId)
+				provisionedInfra, infraTimings, err := inf.Provision(ctx, tenantId, subscriptionId, dnsSubscriptionId, runId, start, func(p Provisioned) error {
+					mu.Lock()
+					defer mu.Unlock()
+
+					provisioned[idx] = p
+					return checkpoint(provisioned)
+				})
+
+				mu.Lock()
+				defer mu.Unlock()
+				timings[idx] = infraTimings
 				if err != nil {
 					return fmt.Errorf("provisioning infrastructure %s: %w", inf.Name, err)
 				}
@@ -205,30 +637,83 @@ func (is infras) Provision(tenantId, subscriptionId string) ([]Provisioned, erro
 				provisioned[idx] = provisionedInfra
 				return nil
 			})
-		}(idx, inf)
+		}(idx, inf, provisioned[idx])
 	}
 
-	if err := eg.Wait(); err != nil {
-		return nil, err
+	err := eg.Wait()
+
+	var ret Timings
+	for _, infraTimings := range timings {
+		ret = append(ret, infraTimings...)
+	}
+	if err != nil {
+		return nil, ret, err
 	}
 
-	return provisioned, nil
+	return provisioned, ret, nil
 }
 
-// Creates Nginx deployment and service for testing
-func deployNginx(ctx context.Context, p Provisioned) (*corev1.Service, *corev1.Service, error) {
-	var objs []client.Object
+// Returns the plan of every infra, resuming each from its stage in saved, which lines up with is and can be nil. Fails
+// when two infras would share a resource group or zone
+func (is infras) Plan(subscriptionId, dnsSubscriptionId, runId string, saved []Provisioned) (string, error) {
+	if err := is.validateNames(runId); err != nil {
+		return "", err
+	}
 
+	b := &strings.Builder{}
+	for idx, inf := range is {
+		completed := is.saved(saved, idx, runId).Stage
+
+		plan, err := inf.plan(subscriptionId, dnsSubscriptionId, runId, completed)
+		if err != nil {
+			return "", fmt.Errorf("planning infrastructure %s: %w", inf.Name, err)
+		}
+
+		resuming := "starting from scratch"
+		if completed != "" {
+			resuming = fmt.Sprintf("resuming after the %s stage once its resources are found", completed)
+		}
+		fmt.Fprintf(b, "plan for %s, %s:\n%s", inf.Name, resuming, plan)
+	}
+	return b.String(), nil
+}
+
+// Returns the state the infra at idx starts from, an empty state named after it unless saved has one
+func (is infras) saved(saved []Provisioned, idx int, runId string) Provisioned {
+	if idx < len(saved) && saved[idx].Name != "" {
+		return saved[idx]
+	}
+	return Provisioned{Name: is[idx].Name, RunId: runId, Suffix: is[idx].Suffix}
+}
+
+// Returns the vnet layout the infra defines, otherwise the layout its cluster options need, otherwise the default
+func (i infra) vnetLayout() clients.VnetLayout {
+	if len(i.Vnet.Subnets) > 0 {
+		return i.Vnet
+	}
+
+	for _, opt := range i.McOpts {
+		if opt.Vnet != nil {
+			return *opt.Vnet
+		}
+	}
+
+	return clients.DefaultVnetLayout
+}
+
+// Returns whether services in the cluster can be given ipv6 addresses
+func hasIpv6(c cluster) bool {
+	_, ok := c.GetOptions()[clients.IpFamilyOption(armcontainerservice.IPFamilyIPv6)]
+	return ok
+}
+
+// Creates Nginx deployment and service for testing. The ipv6 service is nil when the cluster has no ipv6 support
+func deployNginx(ctx context.Context, p Provisioned) (*corev1.Service, *corev1.Service, error) {
 	lgr := logger.FromContext(ctx).With("infra", p.Name)
 	lgr.Info("deploying nginx deployment and service onto cluster")
 	defer lgr.Info("finished deploying nginx resources")
 
-	nginxDeployment := clients.NewNginxDeployment()
-	ipv4Service, ipv6Service := clients.NewNginxServices(p.Zones[0].GetName())
-	objs = append(objs, nginxDeployment)
-	objs = append(objs, ipv4Service)
-	objs = append(objs, ipv6Service)
-
+	objs, ipv4Service, ipv6Service := nginxObjects(p)
 	if err := p.Cluster.Deploy(ctx, objs); err != nil {
 		lgr.Error("Error deploying Nginx resources ")
 		return ipv4Service, ipv6Service, logger.Error(lgr, err)
@@ -238,22 +723,253 @@ func deployNginx(ctx context.Context, p Provisioned) (*corev1.Service, *corev1.S
 
 }
 
-// Deploys ExternalDNS onto cluster
-func deployExternalDNS(ctx context.Context, p Provisioned) error {
+// Returns the nginx deployment and services deployNginx deploys along with the services. The ipv6 service is nil when
+// the cluster has no ipv6 support
+func nginxObjects(p Provisioned) ([]client.Object, *corev1.Service, *corev1.Service) {
+	ipv4Service, ipv6Service := clients.NewNginxServices(p.Zones[0].GetName())
+	objs := []client.Object{clients.NewNginxDeployment(), ipv4Service}
+	if !hasIpv6(p.Cluster) {
+		return objs, ipv4Service, nil
+	}
+
+	return append(objs, ipv6Service), ipv4Service, ipv6Service
+}
+
+// Deploys a public and an internal ingress-nginx controller used by the ingress tests
+func deployIngressNginx(ctx context.Context, p Provisioned) error {
 	lgr := logger.FromContext(ctx).With("infra", p.Name)
-	lgr.Info("deploying external DNS onto cluster")
-	defer lgr.Info("finished deploying ext DNS")
+	lgr.Info("deploying ingress controllers onto cluster")
+	defer lgr.Info("finished deploying ingress controllers")
+
+	if err := p.Cluster.Deploy(ctx, clients.NewIngressNginxResources()); err != nil {
+		lgr.Error("Error deploying ingress controllers")
+		return logger.Error(lgr, err)
+	}
+
+	return nil
+}
 
-	publicZoneName := p.Zones[0].GetName()
-	privateZoneName := p.PrivateZones[0].GetName()
+// Installs Gateway API and Envoy Gateway, then deploys the gateway used by the gateway tests
+func deployGateway(ctx context.Context, p Provisioned) error {
+	lgr := logger.FromContext(ctx).With("infra", p.Name)
+	lgr.Info("deploying gateway onto cluster")
+	defer lgr.Info("finished deploying gateway")
 
-	publicDnsConfig := manifests.GetPublicDnsConfig(p.TenantId, p.SubscriptionId, p.ResourceGroup.GetName(), publicZoneName)
-	privateDnsConfig := manifests.GetPrivateDnsConfig(p.TenantId, p.SubscriptionId, p.ResourceGroup.GetName(), privateZoneName)
+	if err := p.Cluster.ApplyUrl(ctx, clients.EnvoyGatewayInstallUrl); err != nil {
+		lgr.Error("Error installing Envoy Gateway")
+		return logger.Error(lgr, err)
+	}
 
-	exConfig := manifests.SetExampleConfig(p.Cluster.GetClientId(), p.Cluster.GetId(), publicDnsConfig, privateDnsConfig)
-	currentConfig := exConfig[0] //currently only using one config from external_dns_config.go
+	if err := p.Cluster.Deploy(ctx, clients.NewGatewayResources()); err != nil {
+		lgr.Error("Error deploying gateway")
+		return logger.Error(lgr, err)
+	}
+
+	return nil
+}
+
+// Returns the oidc issuer of p's cluster and the external dns configs whose service accounts are federated with the
+// identities external dns and the rbac tests authenticate as
+func federationConfig(p Provisioned) (string, []*manifests.ExternalDnsConfig, error) {
+	issuerUrl := p.Cluster.GetOidcIssuerUrl()
+	if issuerUrl == "" {
+		return "", nil, fmt.Errorf("cluster has no oidc issuer url")
+	}
+
+	dnsConfigs, err := externalDnsConfigs(p)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return issuerUrl, dnsConfigs, nil
+}
+
+// Creates an identity for each of DnsAccesses, named after name, and federates every external dns service account
+// with all of them
+func newRbacIdentities(ctx context.Context, subscriptionId, resourceGroup, name, location, issuerUrl string, dnsConfigs []*manifests.ExternalDnsConfig) (map[DnsAccess]identity, error) {
+	var eg errgroup.Group
+	var mu sync.Mutex
+	ret := map[DnsAccess]identity{}
+
+	for idx, access := range DnsAccesses {
+		func(idx int, access DnsAccess) {
+			eg.Go(func() error {
+				identity, err := newFederatedIdentity(ctx, subscriptionId, resourceGroup, fmt.Sprintf("%srbac%d", name, idx), location, issuerUrl, dnsConfigs)
+				if err != nil {
+					return fmt.Errorf("%s identity: %w", access, err)
+				}
+				mu.Lock()
+				ret[access] = identity
+				mu.Unlock()
+				return nil
+			})
+		}(idx, access)
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Creates a managed identity and federates the service account of every config in dnsConfigs with it
+func newFederatedIdentity(ctx context.Context, subscriptionId, resourceGroup, name, location, issuerUrl string, dnsConfigs []*manifests.ExternalDnsConfig) (identity, error) {
+	identity, err := clients.NewManagedIdentity(ctx, subscriptionId, resourceGroup, name, location)
+	if err != nil {
+		return nil, fmt.Errorf("creating managed identity: %w", err)
+	}
+
+	// sequential since Azure rejects concurrent federated credential writes on one identity
+	for _, dnsConfig := range dnsConfigs {
+		if err := identity.FederateServiceAccount(ctx, issuerUrl, manifests.ExternalDnsNamespace, dnsConfig.ResourceName()); err != nil {
+			return nil, fmt.Errorf("federating service account %s: %w", dnsConfig.ResourceName(), err)
+		}
+	}
+
+	return identity, nil
+}
+
+// Grants each of p.RbacIdentities the roles its DnsAccess describes
+func assignRbacRoles(ctx context.Context, p Provisioned, roles *roleAssigner) error {
+	var eg errgroup.Group
+
+	for access, id := range p.RbacIdentities {
+		principalId := id.GetPrincipalId()
+		switch access {
+		case NoDnsAccess:
+		case ReaderDnsAccess:
+			eg.Go(func() error {
+				role := clients.ReaderRole
+				if err := roles.assign(ctx, p.SubscriptionId, p.ResourceGroup.GetId(), principalId, role); err != nil {
+					return fmt.Errorf("%s identity: %w", ReaderDnsAccess, err)
+				}
+				return nil
+			})
+		case ResourceGroupDnsAccess:
+			eg.Go(func() error {
+				role := clients.DnsContributorRole
+				if err := roles.assign(ctx, p.SubscriptionId, p.ResourceGroup.GetId(), principalId, role); err != nil {
+					return fmt.Errorf("%s identity: %w", ResourceGroupDnsAccess, err)
+				}
+				return nil
+			})
+			eg.Go(func() error {
+				role := clients.PrivateDnsContributorRole
+				if err := roles.assign(ctx, p.SubscriptionId, p.ResourceGroup.GetId(), principalId, role); err != nil {
+					return fmt.Errorf("%s identity: %w", ResourceGroupDnsAccess, err)
+				}
+				return nil
+			})
+		case ZoneDnsAccess:
+			// external dns finds zones by listing the resource group, which the zone scoped roles don't cover
+			eg.Go(func() error {
+				role := clients.ReaderRole
+				if err := roles.assign(ctx, p.SubscriptionId, p.ResourceGroup.GetId(), principalId, role); err != nil {
+					return fmt.Errorf("%s identity: %w", ZoneDnsAccess, err)
+				}
+				return nil
+			})
+			eg.Go(func() error {
+				role := clients.DnsContributorRole
+				if err := roles.assign(ctx, p.Zones[0].GetSubscriptionId(), p.Zones[0].GetId(), principalId, role); err != nil {
+					return fmt.Errorf("%s identity: %w", ZoneDnsAccess, err)
+				}
+				return nil
+			})
+			eg.Go(func() error {
+				role := clients.PrivateDnsContributorRole
+				if err := roles.assign(ctx, p.SubscriptionId, p.PrivateZones[0].GetId(), principalId, role); err != nil {
+					return fmt.Errorf("%s identity: %w", ZoneDnsAccess, err)
+				}
+				return nil
+			})
+		default:
+			return fmt.Errorf("unknown dns access %s", access)
+		}
+	}
+
+	return eg.Wait()
+}
+
+// Adds a new client secret to the service principal of p. The secret isn't saved with the infrastructure so anything
+// deploying external dns after loading p needs one first. Does nothing when p has no service principal
+func (p Provisioned) NewServicePrincipalSecret(ctx context.Context) error {
+	if p.ServicePrincipal == nil {
+		return nil
+	}
+
+	if err := p.ServicePrincipal.NewSecret(ctx, servicePrincipalSecretLifetime); err != nil {
+		return fmt.Errorf("creating service principal secret: %w", err)
+	}
+	return nil
+}
+
+// Returns the principal external dns authenticates as, which needs the dns zone roles
+func (p Provisioned) dnsPrincipalId() string {
+	if p.ServicePrincipal != nil {
+		return p.ServicePrincipal.GetPrincipalId()
+	}
+	if p.WorkloadIdentity != nil {
+		return p.WorkloadIdentity.GetPrincipalId()
+	}
+	return p.Cluster.GetPrincipalId()
+}
+
+// Returns the client id of the identity external dns authenticates as
+func (p Provisioned) dnsClientId() string {
+	if p.ServicePrincipal != nil {
+		return p.ServicePrincipal.GetClientId()
+	}
+	if p.WorkloadIdentity != nil {
+		return p.WorkloadIdentity.GetClientId()
+	}
+	return p.Cluster.GetClientId()
+}
+
+// roleAssigner creates role assignments from concurrent goroutines and keeps every assignment it created
+type roleAssigner struct {
+	mu          sync.Mutex
+	assignments []roleAssignment
+}
+
+func (r *roleAssigner) assign(ctx context.Context, subscriptionId, scope, principalId string, role clients.Role) error {
+	ra, err := clients.NewRoleAssignment(ctx, subscriptionId, scope, principalId, role)
+	if err != nil {
+		return fmt.Errorf("creating %s role assignment: %w", role.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.assignments = append(r.assignments, ra)
+	return nil
+}
+
+// Returns the created assignments sorted by id so the infra file doesn't change between identical runs
+func (r *roleAssigner) created() []roleAssignment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	objs := manifests.ExternalDnsResources(currentConfig.Conf, currentConfig.Deploy, currentConfig.DnsConfigs)
+	ret := append([]roleAssignment{}, r.assignments...)
+	sort.Slice(ret, func(i, j int) bool { return ret[i].GetId() < ret[j].GetId() })
+	return ret
+}
+
+// ExternalDnsOpt changes the configuration of one of the external dns deployments, tests use these to redeploy
+// external dns with flags the default deployment doesn't set
+type ExternalDnsOpt func(dnsConfig *manifests.ExternalDnsConfig)
+
+// Deploys ExternalDNS onto cluster, applying opts on top of the default configuration.
+// Deploying again with fewer opts reverts the earlier changes
+func DeployExternalDNS(ctx context.Context, p Provisioned, opts ...ExternalDnsOpt) error {
+	lgr := logger.FromContext(ctx).With("infra", p.Name)
+	lgr.Info("deploying external DNS onto cluster")
+	defer lgr.Info("finished deploying ext DNS")
+
+	objs, err := externalDnsObjects(p, opts...)
+	if err != nil {
+		return logger.Error(lgr, err)
+	}
 
 	if err := p.Cluster.Deploy(ctx, objs); err != nil {
 		lgr.Error("Error Deploying External DNS")
@@ -263,3 +979,84 @@ func deployExternalDNS(ctx context.Context, p Provisioned) error {
 	return nil
 
 }
+
+// Returns the resources DeployExternalDNS deploys with opts applied
+func externalDnsObjects(p Provisioned, opts ...ExternalDnsOpt) ([]client.Object, error) {
+	dnsConfigs, err := externalDnsConfigs(p, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	exConfig := manifests.SetExampleConfig(p.dnsClientId(), p.Cluster.GetId(), dnsConfigs...)
+	currentConfig := exConfig[0] //currently only using one config from external_dns_config.go
+
+	return manifests.ExternalDnsResources(currentConfig.Conf, currentConfig.Deploy, currentConfig.DnsConfigs), nil
+}
+
+// Returns whether an external dns deployment for provider runs on p's cluster
+func (p Provisioned) DeploysProvider(provider manifests.Provider) bool {
+	return len(p.Providers) == 0 || slices.Contains(p.Providers, provider)
+}
+
+// Returns the configuration of every external dns instance deployed onto p's cluster with opts applied
+func externalDnsConfigs(p Provisioned, opts ...ExternalDnsOpt) ([]*manifests.ExternalDnsConfig, error) {
+	// the zone in the dns resource group gets its own external dns instance, the nested zone is covered by its parent
+	publicZoneIds := []string{p.Zones[0].GetId()}
+	for _, z := range p.Zones {
+		if (p.CentralZoneName != "" && z.GetName() == p.CentralZoneName) || slices.Contains(p.ExtraZoneNames, z.GetName()) {
+			publicZoneIds = append(publicZoneIds, z.GetId())
+		}
+	}
+	privateZoneIds := make([]string, len(p.PrivateZones))
+	for idx, pz := range p.PrivateZones {
+		privateZoneIds[idx] = pz.GetId()
+	}
+
+	var publicDnsConfigs, privateDnsConfigs []*manifests.ExternalDnsConfig
+	var err error
+	if p.DeploysProvider(manifests.PublicProvider) {
+		publicDnsConfigs, err = manifests.GetPublicDnsConfigs(p.TenantId, publicZoneIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.DeploysProvider(manifests.PrivateProvider) {
+		privateDnsConfigs, err = manifests.GetPrivateDnsConfigs(p.TenantId, privateZoneIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, dnsConfig := range publicDnsConfigs {
+		dnsConfig.ManagedRecordTypes = publicManagedRecordTypes
+	}
+	for _, dnsConfig := range privateDnsConfigs {
+		dnsConfig.ManagedRecordTypes = privateManagedRecordTypes
+	}
+
+	// only sources whose resources are installed are watched, external dns fails to start watching the others
+	var extraSources []manifests.Source
+	if p.GatewayName != "" {
+		extraSources = append(extraSources, manifests.GatewayHTTPRouteSource)
+	}
+	if p.DnsEndpointCrd {
+		extraSources = append(extraSources, manifests.CrdSource)
+	}
+
+	dnsConfigs := append(publicDnsConfigs, privateDnsConfigs...)
+	for _, dnsConfig := range dnsConfigs {
+		dnsConfig.ExtraSources = append([]manifests.Source{}, extraSources...)
+		switch {
+		case p.ServicePrincipal != nil:
+			dnsConfig.Auth = manifests.ServicePrincipalAuth
+			dnsConfig.ClientSecret = p.ServicePrincipal.GetSecret()
+		case p.WorkloadIdentity != nil:
+			dnsConfig.Auth = manifests.WorkloadIdentityAuth
+		}
+		for _, opt := range opts {
+			opt(dnsConfig)
+		}
+	}
+
+	return dnsConfigs, nil
+}
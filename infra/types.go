@@ -2,27 +2,46 @@ package infra
 
 import (
 	"context"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	manifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
 )
 
 type infras []infra
 
 type infra struct {
-	Name   string
-	Suffix string
-	// ResourceGroup is a unique new resource group name
-	// for resources to be provisioned inside
-	ResourceGroup, Location string
-	McOpts                  []clients.McOpt
+	Name     string
+	Suffix   string
+	Location string
+	// DnsResourceGroup creates a second resource group for a public zone kept apart from the cluster, the way a
+	// central dns resource group would. No zone is created outside the infra's own resource group when false
+	DnsResourceGroup bool
+	// ServicePrincipal runs external dns as an app registration signing in with a client secret, the only way
+	// clusters outside AKS can authenticate
+	ServicePrincipal bool
+	// Ingress deploys the public and internal ingress-nginx controllers the ingress tests publish through
+	Ingress bool
+	// Gateway installs Gateway API with Envoy Gateway and turns on the gateway-httproute source
+	Gateway bool
+	// DnsEndpoints installs the DNSEndpoint CRD and turns on the crd source
+	DnsEndpoints bool
+	// PublicZones and PrivateZones are how many zones of each kind external dns is configured with, one each when zero.
+	// The zones the filter and zone layout tests add are created on top of these
+	PublicZones, PrivateZones int
+	// Vnet is the layout a cluster option needs, or clients.DefaultVnetLayout, when it has no subnets
+	Vnet clients.VnetLayout
+	// Providers are the external dns deployments to run, all of manifests.Providers when empty
+	Providers []manifests.Provider
+	McOpts    []clients.McOpt
 }
 
 // McOpt specifies what kind of managed cluster to create
@@ -48,6 +67,25 @@ var PrivateClusterOpt = McOpt{
 	},
 }
 
+// DnsAccess is the access to the dns zones granted to one of the identities provisioned for the rbac tests
+type DnsAccess string
+
+const (
+	// NoDnsAccess has no role on the zones or the resource group holding them
+	NoDnsAccess DnsAccess = "no role"
+	// ReaderDnsAccess has Reader on the resource group, enough to list zones and records but not to write them
+	ReaderDnsAccess DnsAccess = "reader"
+	// ResourceGroupDnsAccess has the dns contributor roles on the resource group holding the zones, which every zone in
+	// it inherits
+	ResourceGroupDnsAccess DnsAccess = "resource group scope"
+	// ZoneDnsAccess has the dns contributor roles on the first public zone and the private zone, and only Reader on the
+	// resource group so external dns can list the zones in it
+	ZoneDnsAccess DnsAccess = "zone scope"
+)
+
+// DnsAccesses are the identities provisioned alongside the workload identity
+var DnsAccesses = []DnsAccess{NoDnsAccess, ReaderDnsAccess, ResourceGroupDnsAccess, ZoneDnsAccess}
+
 type Identifier interface {
 	GetId() string
 }
@@ -55,10 +93,13 @@ type Identifier interface {
 type cluster interface {
 	GetVnetId(ctx context.Context) (string, error)
 	Deploy(ctx context.Context, objs []client.Object) error
+	ApplyUrl(ctx context.Context, url string) error
+	HasObjects(ctx context.Context, objs []client.Object) (bool, error)
 	GetPrincipalId() string
 	GetClientId() string
 	GetLocation() string
 	GetDnsServiceIp() string
+	GetOidcIssuerUrl() string
 	GetCluster(ctx context.Context) (*armcontainerservice.ManagedCluster, error)
 	GetOptions() map[string]struct{}
 	Identifier
@@ -68,17 +109,55 @@ type zone interface {
 	GetDnsZone(ctx context.Context) (*armdns.Zone, error)
 	GetName() string
 	GetNameservers() []string
+	GetSubscriptionId() string
+	GetResourceGroup() string
 	Identifier
 }
 
 type privateZone interface {
 	GetDnsZone(ctx context.Context) (*armprivatedns.PrivateZone, error)
 	LinkVnet(ctx context.Context, linkName, vnetId string) error
+	IsVnetLinked(ctx context.Context, linkName, vnetId string) (bool, error)
 	GetName() string
 	Identifier
 }
 
+type vnet interface {
+	Exists(ctx context.Context) (bool, error)
+	GetName() string
+	GetSubnetId(name string) string
+	GetSubnetIds() map[string]string
+	Identifier
+}
+
+type identity interface {
+	GetClientId() string
+	GetPrincipalId() string
+	Exists(ctx context.Context) (bool, error)
+	IsFederated(ctx context.Context, issuerUrl, namespace, serviceAccount string) (bool, error)
+	Identifier
+}
+
+type servicePrincipal interface {
+	GetSecret() string
+	NewSecret(ctx context.Context, lifetime time.Duration) error
+	GetTags() []string
+	Delete(ctx context.Context) error
+	GetClientId() string
+	GetPrincipalId() string
+	Exists(ctx context.Context) (bool, error)
+	Identifier
+}
+
+type roleAssignment interface {
+	GetPrincipalId() string
+	GetRoleName() string
+	Exists(ctx context.Context) (bool, error)
+	Identifier
+}
+
 type resourceGroup interface {
+	Exists(ctx context.Context) (bool, error)
 	GetName() string
 	Identifier
 }
@@ -86,15 +165,66 @@ type resourceGroup interface {
 // Provisioned is a struct that contains all the resources provisioned in by the infra command (provision.go)
 // Configuration is saved in this struct when reading from infrastructure configuration .json file
 type Provisioned struct {
-	Name            string
-	Cluster         cluster
-	ResourceGroup   resourceGroup
-	SubscriptionId  string
-	TenantId        string
-	Zones           []zone
-	PrivateZones    []privateZone
-	Ipv4ServiceName string
+	Name string
+	// RunId prefixes the names of the resource groups and zones, it's shared by every infra provisioned together
+	RunId string
+	// Suffix is the infra's Suffix, kept so a resumed run gives the rest of the resources the same names
+	Suffix string
+	// Stage is the last provisioning stage completed, empty when none is. Resources of later stages may be missing
+	Stage         Stage
+	Cluster       cluster
+	ResourceGroup resourceGroup
+	// DnsResourceGroup is the name of the resource group holding the central zone, empty when there is none
+	DnsResourceGroup string
+	SubscriptionId   string
+	TenantId         string
+	Zones            []zone
+	PrivateZones     []privateZone
+	Ipv4ServiceName  string
+	// Ipv6ServiceName is empty when the cluster has no ipv6 support
 	Ipv6ServiceName string
+	// IngressServiceName and InternalIngressServiceName are the load balancer services of the public and internal ingress controllers
+	IngressServiceName         string
+	InternalIngressServiceName string
+	// GatewayName is the Gateway API gateway HTTPRoutes in the tests attach to, empty when no gateway is installed
+	GatewayName string
+	// DnsEndpointCrd is whether the DNSEndpoint CRD is installed, external dns only reads DNSEndpoints when it is
+	DnsEndpointCrd bool
+	// Vnet is the vnet the cluster runs in, every private zone is linked to it
+	Vnet vnet
+	// InternalLbSubnetName is the subnet internal load balancers can be placed in with the internal-subnet annotation
+	InternalLbSubnetName string
+	// InternalLbSubnetPrefixes are the address ranges of InternalLbSubnetName
+	InternalLbSubnetPrefixes []string
+	// UnfilteredZoneName is a public zone in Zones that external dns has access to but is excluded by the domain filter
+	UnfilteredZoneName string
+	// NestedZoneName is a public zone in Zones delegated from the first public zone, it falls under the domain filter of its parent
+	NestedZoneName string
+	// CentralZoneName is a public zone in Zones that lives in the dns resource group, which can be in another subscription
+	CentralZoneName string
+	// ExtraZoneNames are public zones in Zones external dns is configured with besides the first one
+	ExtraZoneNames []string
+	// Providers are the external dns deployments running on the cluster, all of manifests.Providers when empty
+	Providers []manifests.Provider
+	// WorkloadIdentity is the identity external dns federates with on clusters created with clients.WorkloadIdentityOpt.
+	// External dns authenticates as the kubelet identity when nil
+	WorkloadIdentity identity
+	// ServicePrincipal is the app registration external dns signs in as on infras with ServicePrincipal set
+	ServicePrincipal servicePrincipal
+	// RbacIdentities are federated with the external dns service accounts like WorkloadIdentity but hold only the
+	// roles their DnsAccess describes. Only provisioned with WorkloadIdentity
+	RbacIdentities map[DnsAccess]identity
+	// RoleAssignments are every role assignment provisioning created or found already in place
+	RoleAssignments []roleAssignment
+}
+
+type LoadableIdentity struct {
+	ResourceId            azure.Resource
+	ClientId, PrincipalId string
+}
+
+type LoadableRoleAssignment struct {
+	Id, PrincipalId, Role string
 }
 
 type LoadableZone struct {
@@ -105,9 +235,15 @@ type LoadableZone struct {
 // LoadableProvisioned is a struct that can be used to load a Provisioned struct from a file.
 // Ensure that all fields are exported so that they can properly be serialized/deserialized.
 type LoadableProvisioned struct {
-	Name                                                                      string
+	Name             string
+	RunId            string
+	Suffix           string
+	Stage            Stage
+	DnsResourceGroup string
+	// Cluster and ResourceGroup are only set once the stages creating them complete
 	Cluster                                                                   azure.Resource
 	ClusterLocation, ClusterDnsServiceIp, ClusterPrincipalId, ClusterClientId string
+	ClusterOidcIssuerUrl                                                      string
 	ClusterOptions                                                            map[string]struct{}
 	ResourceGroup                                                             arm.ResourceID // rg id is a little weird and can't be correctly parsed by azure.Resource so we have to use arm.ResourceID
 	SubscriptionId                                                            string
@@ -116,4 +252,29 @@ type LoadableProvisioned struct {
 	PrivateZones                                                              []azure.Resource
 	Ipv4ServiceName                                                           string
 	Ipv6ServiceName                                                           string
+	IngressServiceName, InternalIngressServiceName                            string
+	GatewayName                                                               string
+	DnsEndpointCrd                                                            bool
+	// Vnet is only set when VnetSubnetIds isn't empty
+	Vnet                     azure.Resource
+	VnetSubnetIds            map[string]string
+	InternalLbSubnetName     string
+	InternalLbSubnetPrefixes []string
+	UnfilteredZoneName       string
+	NestedZoneName           string
+	CentralZoneName          string
+	ExtraZoneNames           []string
+	Providers                []manifests.Provider
+	// WorkloadIdentity is only set when WorkloadIdentityClientId isn't empty
+	WorkloadIdentity                                      azure.Resource
+	WorkloadIdentityClientId, WorkloadIdentityPrincipalId string
+	// ServicePrincipal fields are only set when ServicePrincipalClientId isn't empty. The client secret isn't saved since
+	// the file is uploaded as an artifact, a new one is added with NewServicePrincipalSecret after loading
+	ServicePrincipalAppObjectId, ServicePrincipalClientId string
+	ServicePrincipalPrincipalId                           string
+	// ServicePrincipalTags are how cleanup finds the app registration to delete, see servicePrincipalTags
+	ServicePrincipalTags []string
+	RbacIdentities       map[DnsAccess]LoadableIdentity
+	// RoleAssignments are kept to audit what provisioning granted and to delete it, the ids include the scope
+	RoleAssignments []LoadableRoleAssignment
 }
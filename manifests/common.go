@@ -6,12 +6,14 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 const (
@@ -29,9 +31,12 @@ func init() {
 	batchv1.AddToScheme(scheme)
 	corev1.AddToScheme(scheme)
 	metav1.AddMetaToScheme(scheme)
+	networkingv1.AddToScheme(scheme)
 	appsv1.AddToScheme(scheme)
 	policyv1.AddToScheme(scheme)
 	rbacv1.AddToScheme(scheme)
+	gatewayv1.AddToScheme(scheme)
+	addDNSEndpointToScheme(scheme)
 }
 
 // MarshalJson converts an object to json
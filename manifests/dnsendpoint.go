@@ -0,0 +1,78 @@
+package manifests
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DNSEndpointGroupVersion is the group version of the DNSEndpoint CRD read by the external dns crd source
+var DNSEndpointGroupVersion = schema.GroupVersion{Group: "externaldns.k8s.io", Version: "v1alpha1"}
+
+// DNSEndpoint mirrors the external dns DNSEndpoint CRD. External dns doesn't publish its api types as a
+// standalone module so only the fields the tests set are defined here
+type DNSEndpoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DNSEndpointSpec `json:"spec,omitempty"`
+}
+
+type DNSEndpointSpec struct {
+	Endpoints []*Endpoint `json:"endpoints,omitempty"`
+}
+
+// Endpoint is a single record external dns publishes, targets are in the provider independent external dns format
+// e.g. "10 mail.example.com" for MX records
+type Endpoint struct {
+	DNSName    string   `json:"dnsName,omitempty"`
+	Targets    []string `json:"targets,omitempty"`
+	RecordType string   `json:"recordType,omitempty"`
+	RecordTTL  int64    `json:"recordTTL,omitempty"`
+}
+
+type DNSEndpointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DNSEndpoint `json:"items"`
+}
+
+func (in *DNSEndpoint) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := &DNSEndpoint{
+		TypeMeta: in.TypeMeta,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	for _, e := range in.Spec.Endpoints {
+		if e == nil {
+			out.Spec.Endpoints = append(out.Spec.Endpoints, nil)
+			continue
+		}
+		copied := *e
+		copied.Targets = append([]string(nil), e.Targets...)
+		out.Spec.Endpoints = append(out.Spec.Endpoints, &copied)
+	}
+	return out
+}
+
+func (in *DNSEndpointList) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := &DNSEndpointList{
+		TypeMeta: in.TypeMeta,
+	}
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	for _, item := range in.Items {
+		out.Items = append(out.Items, *item.DeepCopyObject().(*DNSEndpoint))
+	}
+	return out
+}
+
+func addDNSEndpointToScheme(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(DNSEndpointGroupVersion, &DNSEndpoint{}, &DNSEndpointList{})
+	metav1.AddToGroupVersion(scheme, DNSEndpointGroupVersion)
+	return nil
+}
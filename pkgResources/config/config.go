@@ -161,3 +161,46 @@ func validateSubAndRg(parsedZone azure.Resource, subscription, resourceGroup str
 
 	return nil
 }
+
+// GroupZoneIDs splits zone resource IDs of one zone type into a DnsZoneConfig per subscription and resource group,
+// in the order each group is first seen. ParseAndValidateZoneIDs rejects zones spread over several groups because an
+// external dns instance only reads zones from a single resource group, so one instance is needed per returned config
+func GroupZoneIDs(zoneIds []string) ([]DnsZoneConfig, error) {
+	var groups []DnsZoneConfig
+	var zoneType string
+
+	for _, zoneId := range zoneIds {
+		// a single zone can't conflict with itself, so this only parses and validates the id
+		zoneConfig := &Config{}
+		if err := zoneConfig.ParseAndValidateZoneIDs(zoneId); err != nil {
+			return nil, err
+		}
+
+		parsed, resourceType := zoneConfig.PublicZoneConfig, PublicZoneType
+		if len(zoneConfig.PrivateZoneConfig.ZoneIds) > 0 {
+			parsed, resourceType = zoneConfig.PrivateZoneConfig, PrivateZoneType
+		}
+		if zoneType != "" && resourceType != zoneType {
+			return nil, fmt.Errorf("while grouping dns zone resource IDs: detected both %s and %s", zoneType, resourceType)
+		}
+		zoneType = resourceType
+
+		found := false
+		for i := range groups {
+			if groups[i].Subscription == parsed.Subscription && groups[i].ResourceGroup == parsed.ResourceGroup {
+				groups[i].ZoneIds = append(groups[i].ZoneIds, zoneId)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, DnsZoneConfig{
+				Subscription:  parsed.Subscription,
+				ResourceGroup: parsed.ResourceGroup,
+				ZoneIds:       []string{zoneId},
+			})
+		}
+	}
+
+	return groups, nil
+}
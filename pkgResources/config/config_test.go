@@ -0,0 +1,194 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	sub      = "00000000-0000-0000-0000-000000000000"
+	otherSub = "11111111-1111-1111-1111-111111111111"
+)
+
+func publicZoneId(subscription, resourceGroup, name string) string {
+	return "/subscriptions/" + subscription + "/resourceGroups/" + resourceGroup + "/providers/Microsoft.Network/dnszones/" + name
+}
+
+func privateZoneId(subscription, resourceGroup, name string) string {
+	return "/subscriptions/" + subscription + "/resourceGroups/" + resourceGroup + "/providers/Microsoft.Network/privatednszones/" + name
+}
+
+func TestParseAndValidateZoneIDs(t *testing.T) {
+	cases := []struct {
+		name    string
+		zoneIds []string
+		// wantErr is part of the expected error, the zones are expected to be accepted when it's empty
+		wantErr     string
+		wantPublic  DnsZoneConfig
+		wantPrivate DnsZoneConfig
+	}{
+		{
+			name:    "zones in one resource group",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(sub, "rg", "b.com")},
+			wantPublic: DnsZoneConfig{
+				Subscription:  sub,
+				ResourceGroup: "rg",
+				ZoneIds:       []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(sub, "rg", "b.com")},
+			},
+		},
+		{
+			name:    "public and private zones",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), privateZoneId(sub, "private-rg", "a.internal")},
+			wantPublic: DnsZoneConfig{
+				Subscription:  sub,
+				ResourceGroup: "rg",
+				ZoneIds:       []string{publicZoneId(sub, "rg", "a.com")},
+			},
+			wantPrivate: DnsZoneConfig{
+				Subscription:  sub,
+				ResourceGroup: "private-rg",
+				ZoneIds:       []string{privateZoneId(sub, "private-rg", "a.internal")},
+			},
+		},
+		{
+			name:    "zones across resource groups",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(sub, "dns-rg", "b.com")},
+			wantErr: "multiple resource groups",
+		},
+		{
+			name:    "zones across subscriptions",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(otherSub, "rg", "b.com")},
+			wantErr: "multiple subscriptions",
+		},
+		{
+			name:    "private zones across resource groups",
+			zoneIds: []string{privateZoneId(sub, "rg", "a.internal"), privateZoneId(sub, "dns-rg", "b.internal")},
+			wantErr: "multiple resource groups",
+		},
+		{
+			name:    "invalid resource id",
+			zoneIds: []string{"not-a-resource-id"},
+			wantErr: "while parsing dns zone resource ID",
+		},
+		{
+			name:    "invalid provider",
+			zoneIds: []string{"/subscriptions/" + sub + "/resourceGroups/rg/providers/Microsoft.Compute/dnszones/a.com"},
+			wantErr: "invalid resource provider",
+		},
+		{
+			name:    "invalid resource type",
+			zoneIds: []string{"/subscriptions/" + sub + "/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet"},
+			wantErr: "invalid resource type",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conf := &Config{}
+			err := conf.ParseAndValidateZoneIDs(strings.Join(c.zoneIds, ","))
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected an error about %s, got %v", c.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(conf.PublicZoneConfig, c.wantPublic) {
+				t.Errorf("expected public zone config %+v, got %+v", c.wantPublic, conf.PublicZoneConfig)
+			}
+			if !reflect.DeepEqual(conf.PrivateZoneConfig, c.wantPrivate) {
+				t.Errorf("expected private zone config %+v, got %+v", c.wantPrivate, conf.PrivateZoneConfig)
+			}
+		})
+	}
+}
+
+func TestGroupZoneIDs(t *testing.T) {
+	cases := []struct {
+		name    string
+		zoneIds []string
+		wantErr string
+		want    []DnsZoneConfig
+	}{
+		{
+			name:    "zones in one resource group",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(sub, "rg", "b.com")},
+			want: []DnsZoneConfig{
+				{Subscription: sub, ResourceGroup: "rg", ZoneIds: []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(sub, "rg", "b.com")}},
+			},
+		},
+		{
+			name:    "zones across resource groups in the order first seen",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(sub, "dns-rg", "b.com"), publicZoneId(sub, "rg", "c.com")},
+			want: []DnsZoneConfig{
+				{Subscription: sub, ResourceGroup: "rg", ZoneIds: []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(sub, "rg", "c.com")}},
+				{Subscription: sub, ResourceGroup: "dns-rg", ZoneIds: []string{publicZoneId(sub, "dns-rg", "b.com")}},
+			},
+		},
+		{
+			name:    "zones across subscriptions",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), publicZoneId(otherSub, "rg", "b.com")},
+			want: []DnsZoneConfig{
+				{Subscription: sub, ResourceGroup: "rg", ZoneIds: []string{publicZoneId(sub, "rg", "a.com")}},
+				{Subscription: otherSub, ResourceGroup: "rg", ZoneIds: []string{publicZoneId(otherSub, "rg", "b.com")}},
+			},
+		},
+		{
+			name:    "private zones across resource groups",
+			zoneIds: []string{privateZoneId(sub, "rg", "a.internal"), privateZoneId(sub, "dns-rg", "b.internal")},
+			want: []DnsZoneConfig{
+				{Subscription: sub, ResourceGroup: "rg", ZoneIds: []string{privateZoneId(sub, "rg", "a.internal")}},
+				{Subscription: sub, ResourceGroup: "dns-rg", ZoneIds: []string{privateZoneId(sub, "dns-rg", "b.internal")}},
+			},
+		},
+		{
+			name:    "public and private zones",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), privateZoneId(sub, "rg", "a.internal")},
+			wantErr: "detected both",
+		},
+		{
+			name:    "invalid resource id",
+			zoneIds: []string{publicZoneId(sub, "rg", "a.com"), "not-a-resource-id"},
+			wantErr: "while parsing dns zone resource ID",
+		},
+		{
+			name:    "invalid resource type",
+			zoneIds: []string{"/subscriptions/" + sub + "/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet"},
+			wantErr: "invalid resource type",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			groups, err := GroupZoneIDs(c.zoneIds)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected an error about %s, got %v", c.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(groups, c.want) {
+				t.Fatalf("expected groups %+v, got %+v", c.want, groups)
+			}
+
+			// every group has to be something a single external dns instance accepts
+			for _, group := range groups {
+				conf := &Config{}
+				if err := conf.ParseAndValidateZoneIDs(strings.Join(group.ZoneIds, ",")); err != nil {
+					t.Errorf("group for resource group %s rejected: %s", group.ResourceGroup, err)
+				}
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
+	"golang.org/x/exp/slices"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -22,6 +23,13 @@ import (
 	"github.com/Azure/azure-provider-external-dns-e2e/pkgResources/util"
 )
 
+const (
+	// WorkloadIdentityClientIdAnnotation on a service account names the identity its pods authenticate as
+	WorkloadIdentityClientIdAnnotation = "azure.workload.identity/client-id"
+	// WorkloadIdentityUseLabel on a pod makes the workload identity webhook inject the federated token
+	WorkloadIdentityUseLabel = "azure.workload.identity/use"
+)
+
 const (
 	replicas                = 1 // this must stay at 1 unless external-dns adds support for multiple replicas https://github.com/kubernetes-sigs/external-dns/issues/2430
 	k8sNameKey              = "app.kubernetes.io/name"
@@ -75,11 +83,119 @@ func (p Provider) Labels() map[string]string {
 	return labels
 }
 
+// Source is a kubernetes resource type external dns reads hostnames from
+type Source string
+
+const (
+	ServiceSource          Source = "service"
+	IngressSource          Source = "ingress"
+	GatewayHTTPRouteSource Source = "gateway-httproute"
+	GatewayGRPCRouteSource Source = "gateway-grpcroute"
+	GatewayTLSRouteSource  Source = "gateway-tlsroute"
+	GatewayTCPRouteSource  Source = "gateway-tcproute"
+	GatewayUDPRouteSource  Source = "gateway-udproute"
+	// CrdSource reads DNSEndpoint objects, the DNSEndpoint CRD must be installed before external dns starts
+	CrdSource Source = "crd"
+)
+
+// Policy controls which changes external dns is allowed to make to records it owns
+type Policy string
+
+const (
+	// SyncPolicy creates, updates and deletes records, it's what external dns uses when no policy is set
+	SyncPolicy Policy = "sync"
+	// UpsertOnlyPolicy creates and updates records but never deletes them
+	UpsertOnlyPolicy Policy = "upsert-only"
+	// CreateOnlyPolicy only creates records, existing records are never updated or deleted
+	CreateOnlyPolicy Policy = "create-only"
+)
+
+// AuthMode is how external dns authenticates to Azure as the identity in config.Config.MSIClientID
+type AuthMode string
+
+const (
+	// ManagedIdentityAuth gets tokens for the identity from the instance metadata service, the identity has to be
+	// assigned to the nodes like the kubelet identity is
+	ManagedIdentityAuth AuthMode = "managed-identity"
+	// WorkloadIdentityAuth exchanges the service account token for a token of the identity, the identity needs a
+	// federated credential for the external dns service account
+	WorkloadIdentityAuth AuthMode = "workload-identity"
+	// ServicePrincipalAuth signs in as the app registration with ExternalDnsConfig.ClientSecret, azure.json is kept in
+	// a Secret instead of a ConfigMap since it holds the secret
+	ServicePrincipalAuth AuthMode = "service-principal"
+)
+
+// DefaultSources are the sources every external dns deployment watches
+var DefaultSources = []Source{IngressSource, ServiceSource}
+
+// gatewayRouteResources maps gateway sources to the route resource external dns lists for them
+var gatewayRouteResources = map[Source]string{
+	GatewayHTTPRouteSource: "httproutes",
+	GatewayGRPCRouteSource: "grpcroutes",
+	GatewayTLSRouteSource:  "tlsroutes",
+	GatewayTCPRouteSource:  "tcproutes",
+	GatewayUDPRouteSource:  "udproutes",
+}
+
 // ExternalDnsConfig defines configuration options for required resources for external dns
 type ExternalDnsConfig struct {
 	TenantId, Subscription, ResourceGroup string
 	Provider                              Provider
-	DnsZoneResourceIDs                    []string
+	// InstanceName tells apart several deployments of the same provider, such as one per resource group holding zones.
+	// The default deployment of each provider leaves it empty
+	InstanceName       string
+	DnsZoneResourceIDs []string
+	// ExtraSources are watched in addition to DefaultSources
+	ExtraSources []Source
+	// ManagedRecordTypes overrides the record types external dns manages, defaults to A, AAAA and CNAME when empty
+	ManagedRecordTypes []string
+	// AnnotationFilter and LabelFilter are label selectors limiting sources to matching objects, e.g. "team=a"
+	AnnotationFilter, LabelFilter string
+	// Namespace limits sources to a single namespace, all namespaces are watched when empty
+	Namespace string
+	// ExcludeDomains are skipped even when they fall under a zone in DnsZoneResourceIDs
+	ExcludeDomains []string
+	// ZoneIdFilter selects zones by passing DnsZoneResourceIDs to --zone-id-filter instead of passing their names to
+	// --domain-filter. A domain filter also matches every zone nested under the named zone, a zone id filter doesn't.
+	// The ids have to match the ones Azure returns exactly since external dns compares them as strings
+	ZoneIdFilter bool
+	// Policy is passed to --policy when set, external dns defaults to SyncPolicy
+	Policy Policy
+	// Auth defaults to ManagedIdentityAuth when empty
+	Auth AuthMode
+	// ClientSecret is the secret of the app registration config.Config.MSIClientID names, only used with ServicePrincipalAuth
+	ClientSecret string
+	// ClientId overrides config.Config.MSIClientID for this instance, tests use it to run external dns as an identity
+	// holding fewer roles
+	ClientId string
+}
+
+// Returns the client id of the identity this instance authenticates as
+func (e *ExternalDnsConfig) clientId(conf *config.Config) string {
+	if e.ClientId != "" {
+		return e.ClientId
+	}
+	return conf.MSIClientID
+}
+
+// ResourceName returns the name of every kubernetes object deployed for this config
+func (e *ExternalDnsConfig) ResourceName() string {
+	if e.InstanceName == "" {
+		return e.Provider.ResourceName()
+	}
+	return e.Provider.ResourceName() + "-" + e.InstanceName
+}
+
+// Labels returns the labels of every kubernetes object deployed for this config
+func (e *ExternalDnsConfig) Labels() map[string]string {
+	return map[string]string{
+		k8sNameKey: e.ResourceName(),
+	}
+}
+
+// Sources returns every source external dns watches for this config
+func (e *ExternalDnsConfig) Sources() []Source {
+	return append(append([]Source{}, DefaultSources...), e.ExtraSources...)
 }
 
 // ExternalDnsResources returns Kubernetes objects required for external dns
@@ -109,14 +225,18 @@ func externalDnsResourcesFromConfig(conf *config.Config, externalDnsConfig *Exte
 	objs = append(objs, newExternalDNSClusterRole(conf, externalDnsConfig))
 	objs = append(objs, newExternalDNSClusterRoleBinding(conf, externalDnsConfig))
 
-	dnsCm, dnsCmHash := NewExternalDNSConfigMap(conf, externalDnsConfig)
-	objs = append(objs, dnsCm)
-	deployment := newExternalDNSDeployment(conf, externalDnsConfig, dnsCmHash)
-
-	objs = append(objs, deployment)
+	if externalDnsConfig.Auth == ServicePrincipalAuth {
+		dnsSecret, dnsSecretHash := newExternalDNSSecret(conf, externalDnsConfig)
+		objs = append(objs, dnsSecret)
+		objs = append(objs, newExternalDNSDeployment(conf, externalDnsConfig, dnsSecretHash))
+	} else {
+		dnsCm, dnsCmHash := NewExternalDNSConfigMap(conf, externalDnsConfig)
+		objs = append(objs, dnsCm)
+		objs = append(objs, newExternalDNSDeployment(conf, externalDnsConfig, dnsCmHash))
+	}
 
 	for _, obj := range objs {
-		l := util.MergeMaps(obj.GetLabels(), externalDnsConfig.Provider.Labels())
+		l := util.MergeMaps(obj.GetLabels(), externalDnsConfig.Labels())
 		obj.SetLabels(l)
 	}
 
@@ -124,27 +244,33 @@ func externalDnsResourcesFromConfig(conf *config.Config, externalDnsConfig *Exte
 }
 
 func newExternalDNSServiceAccount(conf *config.Config, externalDnsConfig *ExternalDnsConfig) *corev1.ServiceAccount {
+	var annotations map[string]string
+	if externalDnsConfig.Auth == WorkloadIdentityAuth {
+		annotations = map[string]string{WorkloadIdentityClientIdAnnotation: externalDnsConfig.clientId(conf)}
+	}
+
 	return &corev1.ServiceAccount{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ServiceAccount",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      externalDnsConfig.Provider.ResourceName(),
-			Namespace: conf.NS,
-			Labels:    GetTopLevelLabels(),
+			Name:        externalDnsConfig.ResourceName(),
+			Namespace:   conf.NS,
+			Labels:      GetTopLevelLabels(),
+			Annotations: annotations,
 		},
 	}
 }
 
 func newExternalDNSClusterRole(conf *config.Config, externalDnsConfig *ExternalDnsConfig) *rbacv1.ClusterRole {
-	return &rbacv1.ClusterRole{
+	role := &rbacv1.ClusterRole{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ClusterRole",
 			APIVersion: "rbac.authorization.k8s.io/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   externalDnsConfig.Provider.ResourceName(),
+			Name:   externalDnsConfig.ResourceName(),
 			Labels: GetTopLevelLabels(),
 		},
 		Rules: []rbacv1.PolicyRule{
@@ -165,6 +291,46 @@ func newExternalDNSClusterRole(conf *config.Config, externalDnsConfig *ExternalD
 			},
 		},
 	}
+
+	// gateway sources resolve routes through their parent gateways and filter gateways by namespace,
+	// without these rules external dns starts but never publishes anything for routes
+	var routeResources []string
+	for _, source := range externalDnsConfig.ExtraSources {
+		if resource, ok := gatewayRouteResources[source]; ok && !slices.Contains(routeResources, resource) {
+			routeResources = append(routeResources, resource)
+		}
+	}
+	if len(routeResources) > 0 {
+		role.Rules = append(role.Rules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"namespaces"},
+				Verbs:     []string{"get", "watch", "list"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{"gateway.networking.k8s.io"},
+				Resources: append([]string{"gateways"}, routeResources...),
+				Verbs:     []string{"get", "watch", "list"},
+			},
+		)
+	}
+
+	if slices.Contains(externalDnsConfig.ExtraSources, CrdSource) {
+		role.Rules = append(role.Rules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{"externaldns.k8s.io"},
+				Resources: []string{"dnsendpoints"},
+				Verbs:     []string{"get", "watch", "list"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{"externaldns.k8s.io"},
+				Resources: []string{"dnsendpoints/status"},
+				Verbs:     []string{"*"},
+			},
+		)
+	}
+
+	return role
 }
 
 func newExternalDNSClusterRoleBinding(conf *config.Config, externalDnsConfig *ExternalDnsConfig) *rbacv1.ClusterRoleBinding {
@@ -174,67 +340,160 @@ func newExternalDNSClusterRoleBinding(conf *config.Config, externalDnsConfig *Ex
 			APIVersion: "rbac.authorization.k8s.io/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   externalDnsConfig.Provider.ResourceName(),
+			Name:   externalDnsConfig.ResourceName(),
 			Labels: GetTopLevelLabels(),
 		},
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "ClusterRole",
-			Name:     externalDnsConfig.Provider.ResourceName(),
+			Name:     externalDnsConfig.ResourceName(),
 		},
 		Subjects: []rbacv1.Subject{{
 			Kind:      "ServiceAccount",
-			Name:      externalDnsConfig.Provider.ResourceName(),
+			Name:      externalDnsConfig.ResourceName(),
 			Namespace: conf.NS,
 		}},
 	}
 }
 
 func NewExternalDNSConfigMap(conf *config.Config, externalDnsConfig *ExternalDnsConfig) (*corev1.ConfigMap, string) {
-
-	js, err := json.Marshal(&map[string]interface{}{
-		"tenantId":                    externalDnsConfig.TenantId,
-		"subscriptionId":              externalDnsConfig.Subscription,
-		"resourceGroup":               externalDnsConfig.ResourceGroup,
-		"userAssignedIdentityID":      conf.MSIClientID,
-		"useManagedIdentityExtension": true,
-		"cloud":                       conf.Cloud,
-		"location":                    conf.Location,
-	})
-	if err != nil {
-		panic(err)
-	}
-	hash := sha256.Sum256(js)
+	js, hash := azureConfigJson(conf, externalDnsConfig)
 	return &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ConfigMap",
 			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      externalDnsConfig.Provider.ResourceName(),
+			Name:      externalDnsConfig.ResourceName(),
 			Namespace: conf.NS,
 			Labels:    GetTopLevelLabels(),
 		},
 		Data: map[string]string{
 			"azure.json": string(js),
 		},
-	}, hex.EncodeToString(hash[:])
+	}, hash
 }
 
-func newExternalDNSDeployment(conf *config.Config, externalDnsConfig *ExternalDnsConfig, configMapHash string) *appsv1.Deployment {
+// newExternalDNSSecret holds azure.json for ServicePrincipalAuth, where it contains the client secret
+func newExternalDNSSecret(conf *config.Config, externalDnsConfig *ExternalDnsConfig) (*corev1.Secret, string) {
+	js, hash := azureConfigJson(conf, externalDnsConfig)
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      externalDnsConfig.ResourceName(),
+			Namespace: conf.NS,
+			Labels:    GetTopLevelLabels(),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"azure.json": js,
+		},
+	}, hash
+}
+
+// Returns the azure.json external dns reads its credentials from and its hash
+func azureConfigJson(conf *config.Config, externalDnsConfig *ExternalDnsConfig) ([]byte, string) {
+	azureConfig := map[string]interface{}{
+		"tenantId":       externalDnsConfig.TenantId,
+		"subscriptionId": externalDnsConfig.Subscription,
+		"resourceGroup":  externalDnsConfig.ResourceGroup,
+		"cloud":          conf.Cloud,
+		"location":       conf.Location,
+	}
+	switch externalDnsConfig.Auth {
+	case WorkloadIdentityAuth:
+		// the webhook also injects the client id from the service account annotation, setting it here restarts the pods
+		// when the identity changes since the service account isn't part of the pod template
+		azureConfig["userAssignedIdentityID"] = externalDnsConfig.clientId(conf)
+		azureConfig["useWorkloadIdentityExtension"] = true
+	case ServicePrincipalAuth:
+		azureConfig["aadClientId"] = externalDnsConfig.clientId(conf)
+		azureConfig["aadClientSecret"] = externalDnsConfig.ClientSecret
+	default:
+		azureConfig["userAssignedIdentityID"] = externalDnsConfig.clientId(conf)
+		azureConfig["useManagedIdentityExtension"] = true
+	}
+
+	js, err := json.Marshal(&azureConfig)
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(js)
+	return js, hex.EncodeToString(hash[:])
+}
+
+// configHash is the hash of azure.json, pods restart when it changes
+func newExternalDNSDeployment(conf *config.Config, externalDnsConfig *ExternalDnsConfig, configHash string) *appsv1.Deployment {
 	domainFilters := []string{}
 
 	for _, zoneId := range externalDnsConfig.DnsZoneResourceIDs {
+		if externalDnsConfig.ZoneIdFilter {
+			domainFilters = append(domainFilters, "--zone-id-filter="+zoneId)
+			continue
+		}
+
 		parsedZone, err := azure.ParseResourceID(zoneId)
 		if err != nil {
 			continue
 		}
 		domainFilters = append(domainFilters, fmt.Sprintf("--domain-filter=%s", parsedZone.ResourceName))
 	}
+	for _, domain := range externalDnsConfig.ExcludeDomains {
+		domainFilters = append(domainFilters, "--exclude-domains="+domain)
+	}
+
+	args := []string{
+		"--provider=" + externalDnsConfig.Provider.String(),
+		"--interval=" + conf.DnsSyncInterval.String(),
+		"--txt-owner-id=" + conf.ClusterUid,
+	}
+	// the resource group in azure.json is only a default, pass it explicitly so each instance stays in its own group
+	if externalDnsConfig.ResourceGroup != "" {
+		args = append(args, "--azure-resource-group="+externalDnsConfig.ResourceGroup)
+	}
+	for _, source := range externalDnsConfig.Sources() {
+		args = append(args, "--source="+string(source))
+	}
+	for _, recordType := range externalDnsConfig.ManagedRecordTypes {
+		args = append(args, "--managed-record-types="+recordType)
+	}
+	if externalDnsConfig.AnnotationFilter != "" {
+		args = append(args, "--annotation-filter="+externalDnsConfig.AnnotationFilter)
+	}
+	if externalDnsConfig.LabelFilter != "" {
+		args = append(args, "--label-filter="+externalDnsConfig.LabelFilter)
+	}
+	if externalDnsConfig.Namespace != "" {
+		args = append(args, "--namespace="+externalDnsConfig.Namespace)
+	}
+	if externalDnsConfig.Policy != "" {
+		args = append(args, "--policy="+string(externalDnsConfig.Policy))
+	}
 
 	podLabels := make(map[string]string)
-	podLabels["app"] = externalDnsConfig.Provider.ResourceName()
-	podLabels["checksum/configmap"] = configMapHash[:16]
+	podLabels["app"] = externalDnsConfig.ResourceName()
+	podLabels["checksum/configmap"] = configHash[:16]
+	if externalDnsConfig.Auth == WorkloadIdentityAuth {
+		podLabels[WorkloadIdentityUseLabel] = "true"
+	}
+
+	azureConfigVolume := corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: externalDnsConfig.ResourceName(),
+			},
+		},
+	}
+	if externalDnsConfig.Auth == ServicePrincipalAuth {
+		azureConfigVolume = corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: externalDnsConfig.ResourceName(),
+			},
+		}
+	}
 
 	return &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
@@ -242,30 +501,24 @@ func newExternalDNSDeployment(conf *config.Config, externalDnsConfig *ExternalDn
 			APIVersion: "apps/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      externalDnsConfig.Provider.ResourceName(),
+			Name:      externalDnsConfig.ResourceName(),
 			Namespace: conf.NS,
 			Labels:    GetTopLevelLabels(),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas:             to.Int32Ptr(replicas),
 			RevisionHistoryLimit: util.Int32Ptr(2),
-			Selector:             &metav1.LabelSelector{MatchLabels: map[string]string{"app": externalDnsConfig.Provider.ResourceName()}},
+			Selector:             &metav1.LabelSelector{MatchLabels: map[string]string{"app": externalDnsConfig.ResourceName()}},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: podLabels,
 				},
 				Spec: *WithPreferSystemNodes(&corev1.PodSpec{
-					ServiceAccountName: externalDnsConfig.Provider.ResourceName(),
+					ServiceAccountName: externalDnsConfig.ResourceName(),
 					Containers: []corev1.Container{*withLivenessProbeMatchingReadiness(withTypicalReadinessProbe(7979, &corev1.Container{
 						Name:  "controller",
 						Image: path.Join(conf.Registry, "/oss/kubernetes/external-dns:v0.14.0"),
-						Args: append([]string{
-							"--provider=" + externalDnsConfig.Provider.String(),
-							"--source=ingress",
-							"--source=service",
-							"--interval=" + conf.DnsSyncInterval.String(),
-							"--txt-owner-id=" + conf.ClusterUid,
-						}, domainFilters...),
+						Args:  append(args, domainFilters...),
 						VolumeMounts: []corev1.VolumeMount{{
 							Name:      "azure-config",
 							MountPath: "/etc/kubernetes",
@@ -283,14 +536,8 @@ func newExternalDNSDeployment(conf *config.Config, externalDnsConfig *ExternalDn
 						},
 					}))},
 					Volumes: []corev1.Volume{{
-						Name: "azure-config",
-						VolumeSource: corev1.VolumeSource{
-							ConfigMap: &corev1.ConfigMapVolumeSource{
-								LocalObjectReference: corev1.LocalObjectReference{
-									Name: externalDnsConfig.Provider.ResourceName(),
-								},
-							},
-						},
+						Name:         "azure-config",
+						VolumeSource: azureConfigVolume,
 					}},
 				}),
 			},
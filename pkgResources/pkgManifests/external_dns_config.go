@@ -1,6 +1,8 @@
 package pkgManifests
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -9,6 +11,9 @@ import (
 	"github.com/Azure/azure-provider-external-dns-e2e/pkgResources/config"
 )
 
+// ExternalDnsNamespace is the namespace every external dns instance is deployed into
+const ExternalDnsNamespace = "kube-system"
+
 type configStruct struct {
 	Name       string
 	Conf       *config.Config
@@ -16,57 +21,51 @@ type configStruct struct {
 	DnsConfigs []*ExternalDnsConfig
 }
 
-// Sets public dns configuration above with values from provisioned infra
-func GetPublicDnsConfig(tenantId, subId, rg string, publicZone string) *ExternalDnsConfig {
-
-	publicDnsConfig := &ExternalDnsConfig{}
-	var publicZonePaths []string
-	i := 0
-
-	path := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/dnszones/%s", subId, rg, publicZone)
-	publicZonePaths = append(publicZonePaths, path)
-	i++
-
-	publicDnsConfig.TenantId = tenantId
-	publicDnsConfig.Subscription = subId
-	publicDnsConfig.ResourceGroup = rg
-	publicDnsConfig.DnsZoneResourceIDs = publicZonePaths
-	publicDnsConfig.Provider = PublicProvider
-
-	return publicDnsConfig
-
+// Returns one public dns configuration per subscription and resource group holding the zones in zoneIds
+func GetPublicDnsConfigs(tenantId string, zoneIds []string) ([]*ExternalDnsConfig, error) {
+	return dnsConfigs(tenantId, PublicProvider, zoneIds)
 }
 
-// Sets private dns configuration above with values from provisioned infra
-func GetPrivateDnsConfig(tenantId, subId, rg string, privateZone string) *ExternalDnsConfig {
-
-	privateDnsConfig := &ExternalDnsConfig{}
-
-	var privateZonePaths []string
-	i := 0
+// Returns one private dns configuration per subscription and resource group holding the private zones in zoneIds
+func GetPrivateDnsConfigs(tenantId string, zoneIds []string) ([]*ExternalDnsConfig, error) {
+	return dnsConfigs(tenantId, PrivateProvider, zoneIds)
+}
 
-	path := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/privatednszones/%s", subId, rg, privateZone)
-	privateZonePaths = append(privateZonePaths, path)
-	i++
+// Groups zoneIds with config.GroupZoneIDs and sets up an external dns instance for each group. The first group keeps
+// the default resource names so tests can find it, the others are named after a hash of their subscription and group
+func dnsConfigs(tenantId string, provider Provider, zoneIds []string) ([]*ExternalDnsConfig, error) {
+	groups, err := config.GroupZoneIDs(zoneIds)
+	if err != nil {
+		return nil, fmt.Errorf("grouping %s zones: %w", provider, err)
+	}
 
-	privateDnsConfig.TenantId = tenantId
-	privateDnsConfig.Subscription = subId
-	privateDnsConfig.ResourceGroup = rg
-	privateDnsConfig.DnsZoneResourceIDs = privateZonePaths
-	privateDnsConfig.Provider = PrivateProvider
+	ret := make([]*ExternalDnsConfig, len(groups))
+	for i, group := range groups {
+		ret[i] = &ExternalDnsConfig{
+			TenantId:           tenantId,
+			Subscription:       group.Subscription,
+			ResourceGroup:      group.ResourceGroup,
+			DnsZoneResourceIDs: group.ZoneIds,
+			Provider:           provider,
+		}
+		if i > 0 {
+			hash := sha256.Sum256([]byte(group.Subscription + "/" + group.ResourceGroup))
+			ret[i].InstanceName = hex.EncodeToString(hash[:])[:8]
+		}
+	}
 
-	return privateDnsConfig
+	return ret, nil
 }
 
 // Initializes Example configuration with public and private dns config. Called from Provision.go
-func SetExampleConfig(clientId, clusterUid string, publicDnsConfig, privateDnsConfig *ExternalDnsConfig) []configStruct {
+func SetExampleConfig(clientId, clusterUid string, dnsConfigs ...*ExternalDnsConfig) []configStruct {
 	//for now, we have one configuration, returning an array of configStructs allows us to rotate between configs if necessary
 	exampleConfigs := []configStruct{
 		{
 			Name:       "full",
-			Conf:       &config.Config{NS: "kube-system", MSIClientID: clientId, ClusterUid: clusterUid, DnsSyncInterval: time.Minute * 3, Registry: "mcr.microsoft.com"},
+			Conf:       &config.Config{NS: ExternalDnsNamespace, MSIClientID: clientId, ClusterUid: clusterUid, DnsSyncInterval: time.Minute * 3, Registry: "mcr.microsoft.com"},
 			Deploy:     nil,
-			DnsConfigs: []*ExternalDnsConfig{publicDnsConfig, privateDnsConfig},
+			DnsConfigs: dnsConfigs,
 		},
 		//add other configs here
 	}
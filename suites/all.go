@@ -15,12 +15,27 @@ func All(infra infra.Provisioned) []tests.Ts {
 	var allSuites [][]test
 	allSuites = append(allSuites, basicSuite(infra))
 	allSuites = append(allSuites, privateDnsSuite(infra))
+	allSuites = append(allSuites, ttlSuite(infra))
+	allSuites = append(allSuites, hostnamesSuite(infra))
+	allSuites = append(allSuites, cnameSuite(infra))
+	allSuites = append(allSuites, ingressSuite(infra))
+	allSuites = append(allSuites, gatewaySuite(infra))
+	allSuites = append(allSuites, dnsEndpointSuite(infra))
+	allSuites = append(allSuites, serviceTypesSuite(infra))
+	allSuites = append(allSuites, filtersSuite(infra))
+	allSuites = append(allSuites, domainFilterSuite(infra))
+	allSuites = append(allSuites, nestedZonesSuite(infra))
+	allSuites = append(allSuites, resourceGroupsSuite(infra))
+	allSuites = append(allSuites, policySuite(infra))
+	allSuites = append(allSuites, authSuite(infra))
+	allSuites = append(allSuites, rbacSuite(infra))
 
-	final := make([]tests.Ts, len(allSuites))
+	final := make([]tests.Ts, 0, len(allSuites))
 
 	for _, suite := range allSuites {
 		ret := make(tests.Ts, len(suite))
 		for j, w := range suite {
+			w.skip = w.skipReason(infra)
 			ret[j] = w
 		}
 		final = append(final, ret)
@@ -32,6 +47,10 @@ func All(infra infra.Provisioned) []tests.Ts {
 type test struct {
 	name string
 	run  func(ctx context.Context) error
+	// requires are the capabilities the infrastructure needs for the test to run
+	requires []capability
+	// skip is why the test is skipped, set by All when the infrastructure lacks a required capability
+	skip string
 }
 
 func (t test) GetName() string {
@@ -43,5 +62,9 @@ func (t test) Run(ctx context.Context) error {
 		return fmt.Errorf("no run function provided for test %s", t.GetName())
 	}
 
+	if t.skip != "" {
+		return tests.Skip(t.skip)
+	}
+
 	return t.run(ctx)
 }
@@ -0,0 +1,152 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	pkgManifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+// federatedTokenEnv is injected by the workload identity webhook into pods labeled with pkgManifests.WorkloadIdentityUseLabel
+const federatedTokenEnv = "AZURE_FEDERATED_TOKEN_FILE"
+
+// Tests how external dns authenticates to Azure. Records published by the other suites prove the identity has
+// access, these tests prove it's the identity the infrastructure was meant to use
+func authSuite(in infra.Provisioned) []test {
+	return []test{
+		{
+			name:     "workload identity",
+			requires: []capability{workloadIdentityCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				if err := WorkloadIdentityTest(ctx, in); err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Workload identity test finished successfully ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "service principal",
+			requires: []capability{servicePrincipalCapability, publicDnsCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := ServicePrincipalTest(ctx, in)
+				tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+				if tests.Ipv6Service != nil {
+					tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv6Service.Name)
+				}
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Service principal test finished successfully, clearing service annotations ======== \n")
+				return nil
+			},
+		},
+	}
+}
+
+// Checks the public and private external dns pods had the federated token injected for the workload identity
+var WorkloadIdentityTest = func(ctx context.Context, in infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting workload identity test")
+
+	for _, provider := range pkgManifests.Providers {
+		pods, err := tests.GetPods(ctx, tests.SubId, tests.ResourceGroup, *tests.ClusterName, "app="+provider.ResourceName())
+		if err != nil {
+			return fmt.Errorf("getting %s pods: %w", provider.ResourceName(), err)
+		}
+		if len(pods) == 0 {
+			return fmt.Errorf("no %s pods found", provider.ResourceName())
+		}
+
+		for _, pod := range pods {
+			if pod.Labels[pkgManifests.WorkloadIdentityUseLabel] != "true" {
+				return fmt.Errorf("pod %s is missing the %s label", pod.Name, pkgManifests.WorkloadIdentityUseLabel)
+			}
+			if err := checkFederatedToken(pod, in.WorkloadIdentity.GetClientId()); err != nil {
+				return fmt.Errorf("pod %s: %w", pod.Name, err)
+			}
+		}
+	}
+
+	lgr.Info("Test Passed: Workload identity")
+	return nil
+}
+
+// Returns an error unless a container in pod has the federated token and the client id of the identity
+func checkFederatedToken(pod corev1.Pod, clientId string) error {
+	for _, container := range pod.Spec.Containers {
+		env := map[string]string{}
+		for _, e := range container.Env {
+			env[e.Name] = e.Value
+		}
+		if _, ok := env[federatedTokenEnv]; !ok {
+			continue
+		}
+		if env["AZURE_CLIENT_ID"] != clientId {
+			return fmt.Errorf("container %s authenticates as client id %s, expected %s", container.Name, env["AZURE_CLIENT_ID"], clientId)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no container has %s, the workload identity webhook didn't mutate the pod", federatedTokenEnv)
+}
+
+// Checks the public and private external dns pods read azure.json from a Secret, then runs the basic record tests the cluster supports.
+// The service principal is the only principal holding the dns zone roles on this infra so the records prove it signed in
+var ServicePrincipalTest = func(ctx context.Context, in infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting service principal test")
+
+	for _, provider := range pkgManifests.Providers {
+		pods, err := tests.GetPods(ctx, tests.SubId, tests.ResourceGroup, *tests.ClusterName, "app="+provider.ResourceName())
+		if err != nil {
+			return fmt.Errorf("getting %s pods: %w", provider.ResourceName(), err)
+		}
+		if len(pods) == 0 {
+			return fmt.Errorf("no %s pods found", provider.ResourceName())
+		}
+
+		for _, pod := range pods {
+			if err := checkAzureConfigSecret(pod); err != nil {
+				return fmt.Errorf("pod %s: %w", pod.Name, err)
+			}
+		}
+	}
+
+	if err := ARecordTest(ctx, in); err != nil {
+		return fmt.Errorf("A record test with service principal: %w", err)
+	}
+	if ipv6Capability.has(in) {
+		if err := AAAARecordTest(ctx, in); err != nil {
+			return fmt.Errorf("AAAA record test with service principal: %w", err)
+		}
+	}
+
+	lgr.Info("Test Passed: Service principal")
+	return nil
+}
+
+// Returns an error unless every volume of pod mounting azure.json comes from a Secret, the client secret must never be
+// written to a ConfigMap
+func checkAzureConfigSecret(pod corev1.Pod) error {
+	found := false
+	for _, volume := range pod.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			return fmt.Errorf("volume %s mounts ConfigMap %s, expected azure.json in a Secret", volume.Name, volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no volume mounts a Secret")
+	}
+	return nil
+}
@@ -19,7 +19,8 @@ import (
 func basicSuite(in infra.Provisioned) []test {
 	return []test{
 		{
-			name: "public DNS +  A Record",
+			name:     "public DNS +  A Record",
+			requires: []capability{publicDnsCapability},
 			run: func(ctx context.Context) error {
 				lgr := logger.FromContext(ctx)
 
@@ -33,7 +34,8 @@ func basicSuite(in infra.Provisioned) []test {
 			},
 		},
 		{
-			name: "public DNS +  Quad A Record",
+			name:     "public DNS +  Quad A Record",
+			requires: []capability{publicDnsCapability, ipv6Capability},
 			run: func(ctx context.Context) error {
 				lgr := logger.FromContext(ctx)
 				if err := AAAARecordTest(ctx, in); err != nil {
@@ -75,7 +77,7 @@ var ARecordTest = func(ctx context.Context, infra infra.Provisioned) error {
 	}
 
 	//test passed, deleting created record set
-	err = tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, armdns.RecordTypeA, "")
+	err = tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, "@", armdns.RecordTypeA, "")
 	if err != nil {
 		lgr.Error("Error deleting A record set")
 		return fmt.Errorf("error deleting A record set")
@@ -116,12 +118,12 @@ var AAAARecordTest = func(ctx context.Context, infra infra.Provisioned) error {
 	}
 
 	// Test passed, deleting created record sets
-	err = tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, armdns.RecordTypeA, "")
+	err = tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, "@", armdns.RecordTypeA, "")
 	if err != nil {
 		lgr.Error("Error deleting A record set")
 		return fmt.Errorf("error deleting A record set")
 	}
-	err = tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, armdns.RecordTypeAAAA, "")
+	err = tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, "@", armdns.RecordTypeAAAA, "")
 	if err != nil {
 		lgr.Error("Error deleting AAAA record set")
 		return fmt.Errorf("error deleting AAAA record set")
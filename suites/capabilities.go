@@ -0,0 +1,108 @@
+package suites
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	pkgManifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+)
+
+// capability is something a test needs from the infrastructure. Tests on infrastructure lacking one are skipped
+// with the capability as the reason instead of failing
+type capability struct {
+	name string
+	has  func(in infra.Provisioned) bool
+}
+
+var (
+	ipv6Capability = capability{
+		name: "ipv6",
+		has: func(in infra.Provisioned) bool {
+			_, ok := in.Cluster.GetOptions()[clients.IpFamilyOption(armcontainerservice.IPFamilyIPv6)]
+			return ok && in.Ipv6ServiceName != ""
+		},
+	}
+	// ipv4PrimaryCapability is needed by tests publishing services without an ip family, they only get ipv4 addresses
+	// when ipv4 is the primary family
+	ipv4PrimaryCapability = capability{
+		name: "ipv4 primary family",
+		has: func(in infra.Provisioned) bool {
+			_, ok := in.Cluster.GetOptions()[clients.IPv6PrimaryOpt.Name]
+			return !ok
+		},
+	}
+	publicDnsCapability = capability{
+		name: "public dns",
+		has: func(in infra.Provisioned) bool {
+			return in.DeploysProvider(pkgManifests.PublicProvider) && len(in.Zones) > 0
+		},
+	}
+	// linkedVnetCapability is needed to resolve private dns records, the private zones are only reachable from the vnet
+	// they're linked to
+	linkedVnetCapability = capability{
+		name: "private dns zone linked to a vnet",
+		has: func(in infra.Provisioned) bool {
+			return in.DeploysProvider(pkgManifests.PrivateProvider) && len(in.PrivateZones) > 0 && in.Vnet != nil
+		},
+	}
+	internalLbSubnetCapability = capability{
+		name: "internal load balancer subnet",
+		has:  func(in infra.Provisioned) bool { return in.InternalLbSubnetName != "" },
+	}
+	unfilteredZoneCapability = capability{
+		name: "zone outside the domain filter",
+		has:  func(in infra.Provisioned) bool { return in.UnfilteredZoneName != "" },
+	}
+	nestedZoneCapability = capability{
+		name: "nested zone",
+		has:  func(in infra.Provisioned) bool { return in.NestedZoneName != "" },
+	}
+	centralZoneCapability = capability{
+		name: "zone in a dns resource group",
+		has:  func(in infra.Provisioned) bool { return in.CentralZoneName != "" },
+	}
+	ingressCapability = capability{
+		name: "ingress controllers",
+		has: func(in infra.Provisioned) bool {
+			return in.IngressServiceName != "" && in.InternalIngressServiceName != ""
+		},
+	}
+	gatewayCapability = capability{
+		name: "gateway",
+		has:  func(in infra.Provisioned) bool { return in.GatewayName != "" },
+	}
+	dnsEndpointCapability = capability{
+		name: "DNSEndpoint CRD",
+		has:  func(in infra.Provisioned) bool { return in.DnsEndpointCrd },
+	}
+	workloadIdentityCapability = capability{
+		name: "workload identity",
+		has:  func(in infra.Provisioned) bool { return in.WorkloadIdentity != nil },
+	}
+	servicePrincipalCapability = capability{
+		name: "service principal",
+		has:  func(in infra.Provisioned) bool { return in.ServicePrincipal != nil },
+	}
+	rbacIdentitiesCapability = capability{
+		name: "restricted rbac identities",
+		has:  func(in infra.Provisioned) bool { return len(in.RbacIdentities) > 0 },
+	}
+)
+
+// Returns why t can't run on in, empty when in has every capability t requires
+func (t test) skipReason(in infra.Provisioned) string {
+	var missing []string
+	for _, c := range t.requires {
+		if !c.has(in) {
+			missing = append(missing, c.name)
+		}
+	}
+
+	if len(missing) == 0 {
+		return ""
+	}
+	return "infrastructure has no " + strings.Join(missing, ", ")
+}
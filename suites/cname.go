@@ -0,0 +1,291 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	// cnameTarget is the hostname CNAME records are pointed at, it doesn't need to resolve
+	cnameTarget             = "e2e-target.example.com"
+	externalNameServiceName = "nginx-svc-externalname"
+)
+
+// Tests creating CNAME records through the target annotation and ExternalName services
+func cnameSuite(in infra.Provisioned) []test {
+	return []test{
+		{
+			name:     "public DNS + CNAME target annotation",
+			requires: []capability{publicDnsCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := CnameTargetTest(ctx, in)
+				tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns CNAME target test finished successfully, clearing service annotations ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "private DNS + CNAME target annotation",
+			requires: []capability{linkedVnetCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := PrivateCnameTargetTest(ctx, in)
+				tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Private Dns CNAME target test finished successfully, clearing service annotations ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "public DNS + CNAME ExternalName service",
+			requires: []capability{publicDnsCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := CnameExternalNameTest(ctx, in)
+				tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, externalNameServiceName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns CNAME ExternalName test finished successfully, deleting service ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "private DNS + CNAME ExternalName service",
+			requires: []capability{linkedVnetCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := PrivateCnameExternalNameTest(ctx, in)
+				tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, externalNameServiceName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Private Dns CNAME ExternalName test finished successfully, deleting service ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "public DNS + CNAME at zone apex",
+			requires: []capability{publicDnsCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := CnameApexTest(ctx, in)
+				tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns CNAME apex test finished successfully, clearing service annotations ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "private DNS + CNAME at zone apex",
+			requires: []capability{linkedVnetCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := PrivateCnameApexTest(ctx, in)
+				tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Private Dns CNAME apex test finished successfully, clearing service annotations ======== \n")
+				return nil
+			},
+		},
+	}
+}
+
+var CnameTargetTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + CNAME target annotation test")
+
+	relativeName := "cname-target"
+	annotationMap := map[string]string{
+		tests.HostnameAnnotation: relativeName + "." + tests.PublicZone,
+		tests.TargetAnnotation:   cnameTarget,
+	}
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, annotationMap); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+
+	if err := validateCname(ctx, relativeName, cnameTarget, infra.Cluster.GetId()); err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + CNAME target annotation")
+	return nil
+}
+
+var PrivateCnameTargetTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + CNAME target annotation test")
+
+	relativeName := "cname-target"
+	annotationMap := map[string]string{
+		tests.HostnameAnnotation: relativeName + "." + tests.PrivateZone,
+		tests.TargetAnnotation:   cnameTarget,
+	}
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, annotationMap); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+
+	if err := validatePrivateCname(ctx, relativeName, cnameTarget, infra.Cluster.GetId()); err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Private dns + CNAME target annotation")
+	return nil
+}
+
+var CnameExternalNameTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + CNAME ExternalName service test")
+
+	relativeName := "cname-externalname"
+	svc := clients.NewExternalNameService(externalNameServiceName, cnameTarget)
+	svc.Annotations = map[string]string{
+		tests.HostnameAnnotation: relativeName + "." + tests.PublicZone,
+	}
+	if err := infra.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying ExternalName service: %w", err)
+	}
+
+	if err := validateCname(ctx, relativeName, cnameTarget, infra.Cluster.GetId()); err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + CNAME ExternalName service")
+	return nil
+}
+
+var PrivateCnameExternalNameTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + CNAME ExternalName service test")
+
+	relativeName := "cname-externalname"
+	svc := clients.NewExternalNameService(externalNameServiceName, cnameTarget)
+	svc.Annotations = map[string]string{
+		tests.HostnameAnnotation: relativeName + "." + tests.PrivateZone,
+	}
+	if err := infra.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying ExternalName service: %w", err)
+	}
+
+	if err := validatePrivateCname(ctx, relativeName, cnameTarget, infra.Cluster.GetId()); err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Private dns + CNAME ExternalName service")
+	return nil
+}
+
+// DNS doesn't allow a CNAME alongside the SOA and NS records at the zone apex so Azure must refuse it
+var CnameApexTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + CNAME at zone apex test")
+
+	annotationMap := map[string]string{
+		tests.HostnameAnnotation: tests.PublicZone,
+		tests.TargetAnnotation:   cnameTarget,
+	}
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, annotationMap); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+
+	if err := ensureNoRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, "@", armdns.RecordType(tests.Cname), recordTimeout); err != nil {
+		return fmt.Errorf("CNAME at zone apex was not refused: %w", err)
+	}
+
+	// the zone must still be usable, the apex NS records are what a CNAME would have clobbered
+	if _, err := tests.GetRecordSet(ctx, tests.SubId, tests.ResourceGroup, tests.PublicZone, "@", armdns.RecordTypeNS); err != nil {
+		return fmt.Errorf("zone apex NS records missing after CNAME was refused: %w", err)
+	}
+
+	lgr.Info("Test Passed: Public dns + CNAME at zone apex")
+	return nil
+}
+
+var PrivateCnameApexTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + CNAME at zone apex test")
+
+	annotationMap := map[string]string{
+		tests.HostnameAnnotation: tests.PrivateZone,
+		tests.TargetAnnotation:   cnameTarget,
+	}
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, annotationMap); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+
+	if err := ensureNoPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, "@", armprivatedns.RecordType(tests.Cname), recordTimeout); err != nil {
+		return fmt.Errorf("CNAME at private zone apex was not refused: %w", err)
+	}
+
+	if _, err := tests.GetPrivateRecordSet(ctx, tests.SubId, tests.ResourceGroup, tests.PrivateZone, "@", armprivatedns.RecordTypeSOA); err != nil {
+		return fmt.Errorf("private zone apex SOA record missing after CNAME was refused: %w", err)
+	}
+
+	lgr.Info("Test Passed: Private dns + CNAME at zone apex")
+	return nil
+}
+
+// Checks the public CNAME record set and its ownership TXT record, then deletes both
+func validateCname(ctx context.Context, relativeName, target, owner string) error {
+	lgr := logger.FromContext(ctx)
+	txtName := ownershipRecordName(relativeName, tests.Cname)
+
+	_, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, relativeName, armdns.RecordType(tests.Cname), recordTimeout, cnameRecordCheck(target))
+	if err == nil {
+		_, err = waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, txtName, armdns.RecordTypeTXT, recordTimeout, ownershipCheck(owner))
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, relativeName, armdns.RecordType(tests.Cname), ""); delErr != nil {
+		lgr.Error("Error deleting CNAME record set")
+	}
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, txtName, armdns.RecordTypeTXT, ""); delErr != nil {
+		lgr.Error("Error deleting TXT record set")
+	}
+
+	if err != nil {
+		return fmt.Errorf("CNAME record %s not created in Azure DNS: %w", relativeName, err)
+	}
+	return nil
+}
+
+// Checks the private CNAME record set and its ownership TXT record, then deletes both
+func validatePrivateCname(ctx context.Context, relativeName, target, owner string) error {
+	lgr := logger.FromContext(ctx)
+	txtName := ownershipRecordName(relativeName, tests.Cname)
+
+	_, err := waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, relativeName, armprivatedns.RecordType(tests.Cname), recordTimeout, privateCnameRecordCheck(target))
+	if err == nil {
+		_, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, txtName, armprivatedns.RecordTypeTXT, recordTimeout, privateOwnershipCheck(owner))
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, relativeName, "", armprivatedns.RecordType(tests.Cname)); delErr != nil {
+		lgr.Error("Error deleting private CNAME record set")
+	}
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, txtName, "", armprivatedns.RecordTypeTXT); delErr != nil {
+		lgr.Error("Error deleting private TXT record set")
+	}
+
+	if err != nil {
+		return fmt.Errorf("CNAME record %s not created in Azure Private DNS: %w", relativeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/manifests"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+// endpointCase is a single DNSEndpoint record and how it should appear in Azure.
+// The azure providers in external dns v0.14.0 drop record types they can't write instead of failing. Cases for those
+// types are skipped with unsupported as the reason rather than passing because no record appeared, clear it once the
+// provider gains the type and the typed check runs
+type endpointCase struct {
+	recordType   tests.IpFamily
+	relativeName string
+	// targets in the external dns format for recordType
+	targets []string
+	// unsupported is the provider gap the case is skipped for, empty when the provider writes recordType
+	unsupported string
+}
+
+// srvUnsupported is why SRV records aren't published, see endpointCase
+const srvUnsupported = "the azure providers in external dns v0.14.0 drop SRV records"
+
+// srvSupported is false while the azure providers drop SRV records
+const srvSupported = srvUnsupported == ""
+
+type publicEndpointCase struct {
+	endpointCase
+	check func(*armdns.RecordSet) error
+}
+
+type privateEndpointCase struct {
+	endpointCase
+	check func(*armprivatedns.RecordSet) error
+}
+
+var publicEndpointCases = []publicEndpointCase{
+	{
+		endpointCase: endpointCase{recordType: tests.Mx, relativeName: "mail", targets: []string{"10 mailhost.example.com"}},
+		check:        mxRecordCheck(10, "mailhost.example.com"),
+	},
+	{
+		endpointCase: endpointCase{recordType: tests.Srv, relativeName: "_sip._tcp", targets: []string{"10 50 5060 sip.example.com"}, unsupported: srvUnsupported},
+		check:        srvRecordCheck(10, 50, 5060, "sip.example.com"),
+	},
+	{
+		endpointCase: endpointCase{recordType: tests.Txt, relativeName: "txt", targets: []string{"e2e-verification=external-dns"}},
+		check:        txtRecordCheck("e2e-verification=external-dns"),
+	},
+	{
+		endpointCase: endpointCase{recordType: tests.Ns, relativeName: "delegated", targets: []string{"ns1.example.com"}},
+		check:        nsRecordCheck("ns1.example.com"),
+	},
+	{
+		endpointCase: endpointCase{recordType: tests.Caa, relativeName: "caa", targets: []string{`0 issue "letsencrypt.org"`}, unsupported: "the azure provider in external dns v0.14.0 drops CAA records"},
+		check:        caaRecordCheck(0, "issue", "letsencrypt.org"),
+	},
+}
+
+// Azure private DNS has no NS or CAA record sets
+var privateEndpointCases = []privateEndpointCase{
+	{
+		endpointCase: endpointCase{recordType: tests.Mx, relativeName: "mail", targets: []string{"10 mailhost.example.com"}},
+		check:        privateMxRecordCheck(10, "mailhost.example.com"),
+	},
+	{
+		endpointCase: endpointCase{recordType: tests.Srv, relativeName: "_sip._tcp", targets: []string{"10 50 5060 sip.example.com"}, unsupported: srvUnsupported},
+		check:        privateSrvRecordCheck(10, 50, 5060, "sip.example.com"),
+	},
+	{
+		endpointCase: endpointCase{recordType: tests.Txt, relativeName: "txt", targets: []string{"e2e-verification=external-dns"}},
+		check:        privateTxtRecordCheck("e2e-verification=external-dns"),
+	},
+}
+
+// Returns the name of the DNSEndpoint object for c in the public or private zone
+func (c endpointCase) objectName(private bool) string {
+	name := "dnsendpoint-" + strings.ToLower(string(c.recordType))
+	if private {
+		return name + "-private"
+	}
+	return name
+}
+
+// Returns a DNSEndpoint publishing c under zoneName
+func (c endpointCase) dnsEndpoint(zoneName string, private bool) *manifests.DNSEndpoint {
+	return clients.NewDNSEndpoint(c.objectName(private), &manifests.Endpoint{
+		DNSName:    c.relativeName + "." + zoneName,
+		Targets:    c.targets,
+		RecordType: string(c.recordType),
+	})
+}
+
+// Tests using the crd source to publish record types services and ingresses can't produce
+func dnsEndpointSuite(in infra.Provisioned) []test {
+	var ret []test
+	for _, c := range publicEndpointCases {
+		func(c publicEndpointCase) {
+			ret = append(ret, test{
+				name:     "public DNS + DNSEndpoint " + string(c.recordType),
+				requires: []capability{publicDnsCapability, dnsEndpointCapability},
+				run: func(ctx context.Context) error {
+					lgr := logger.FromContext(ctx)
+					err := DNSEndpointTest(ctx, in, c)
+					tests.DeleteDNSEndpoint(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, c.objectName(false))
+					if err != nil {
+						return err
+					}
+					lgr.Info("\n ======== Public Dns DNSEndpoint " + string(c.recordType) + " test finished successfully, deleting DNSEndpoint ======== \n")
+					return nil
+				},
+			})
+		}(c)
+	}
+
+	for _, c := range privateEndpointCases {
+		func(c privateEndpointCase) {
+			ret = append(ret, test{
+				name:     "private DNS + DNSEndpoint " + string(c.recordType),
+				requires: []capability{linkedVnetCapability, dnsEndpointCapability},
+				run: func(ctx context.Context) error {
+					lgr := logger.FromContext(ctx)
+					err := PrivateDNSEndpointTest(ctx, in, c)
+					tests.DeleteDNSEndpoint(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, c.objectName(true))
+					if err != nil {
+						return err
+					}
+					lgr.Info("\n ======== Private Dns DNSEndpoint " + string(c.recordType) + " test finished successfully, deleting DNSEndpoint ======== \n")
+					return nil
+				},
+			})
+		}(c)
+	}
+
+	return ret
+}
+
+var DNSEndpointTest = func(ctx context.Context, infra infra.Provisioned, c publicEndpointCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + DNSEndpoint " + string(c.recordType) + " test")
+
+	if c.unsupported != "" {
+		return tests.Skip(c.unsupported)
+	}
+
+	if err := infra.Cluster.Deploy(ctx, []client.Object{c.dnsEndpoint(tests.PublicZone, false)}); err != nil {
+		return fmt.Errorf("error deploying DNSEndpoint: %w", err)
+	}
+
+	recordType := armdns.RecordType(c.recordType)
+	_, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, c.relativeName, recordType, recordTimeout, c.check)
+	if err != nil {
+		err = fmt.Errorf("%s record set %s not created in Azure DNS: %w", c.recordType, c.relativeName, err)
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, c.relativeName, recordType, ""); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting " + string(c.recordType) + " record set " + c.relativeName)
+	}
+	ownerName := ownershipRecordName(c.relativeName, c.recordType)
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, ownerName, armdns.RecordTypeTXT, ""); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting ownership TXT record set " + ownerName)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + DNSEndpoint " + string(c.recordType))
+	return nil
+}
+
+var PrivateDNSEndpointTest = func(ctx context.Context, infra infra.Provisioned, c privateEndpointCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + DNSEndpoint " + string(c.recordType) + " test")
+
+	if c.unsupported != "" {
+		return tests.Skip(c.unsupported)
+	}
+
+	if err := infra.Cluster.Deploy(ctx, []client.Object{c.dnsEndpoint(tests.PrivateZone, true)}); err != nil {
+		return fmt.Errorf("error deploying DNSEndpoint: %w", err)
+	}
+
+	recordType := armprivatedns.RecordType(c.recordType)
+	_, err := waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, c.relativeName, recordType, recordTimeout, c.check)
+	if err != nil {
+		err = fmt.Errorf("%s record set %s not created in Azure Private DNS: %w", c.recordType, c.relativeName, err)
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, c.relativeName, "", recordType); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting private " + string(c.recordType) + " record set " + c.relativeName)
+	}
+	ownerName := ownershipRecordName(c.relativeName, c.recordType)
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, ownerName, "", armprivatedns.RecordTypeTXT); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting private ownership TXT record set " + ownerName)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Private dns + DNSEndpoint " + string(c.recordType))
+	return nil
+}
@@ -0,0 +1,125 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	pkgManifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	domainFilterServiceName = "nginx-svc-domain-filter"
+	unfilteredName          = "unfiltered"
+	allowedName             = "allowed"
+	// excludedDomain is relative to the public zone, it and everything below it is passed to --exclude-domains
+	excludedDomain = "excluded"
+)
+
+// Tests that external dns leaves zones and domains outside its filters alone even though it has access to them
+func domainFilterSuite(in infra.Provisioned) []test {
+	return []test{
+		{
+			name:     "public DNS + zone outside domain filter",
+			requires: []capability{publicDnsCapability, unfilteredZoneCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := DomainFilterTest(ctx, in)
+				tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, domainFilterServiceName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns domain filter test finished successfully, deleting service ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "public DNS + exclude domains",
+			requires: []capability{publicDnsCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := ExcludeDomainsTest(ctx, in)
+				tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, domainFilterServiceName)
+				if restoreErr := infra.DeployExternalDNS(ctx, in); restoreErr != nil {
+					return fmt.Errorf("restoring external dns: %w", restoreErr)
+				}
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns exclude domains test finished successfully, restoring external dns ======== \n")
+				return nil
+			},
+		},
+	}
+}
+
+// Publishes a hostname in a zone external dns can write to but that isn't in its domain filter, no record should
+// appear within two sync intervals
+var DomainFilterTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + domain filter test")
+
+	if tests.UnfilteredZone == "" {
+		return fmt.Errorf("unfiltered zone was not provisioned for this infrastructure")
+	}
+
+	svc := clients.NewExternalNameService(domainFilterServiceName, cnameTarget, clients.WithAnnotations(map[string]string{
+		tests.HostnameAnnotation: unfilteredName + "." + tests.UnfilteredZone,
+	}))
+	if err := infra.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying service: %w", err)
+	}
+
+	if err := ensureNoRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.UnfilteredZone, unfilteredName, armdns.RecordType(tests.Cname), recordTimeout); err != nil {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.UnfilteredZone, unfilteredName, armdns.RecordType(tests.Cname), ""); delErr != nil {
+			lgr.Error("Error deleting CNAME record set " + unfilteredName)
+		}
+		return fmt.Errorf("record published into zone outside the domain filter: %w", err)
+	}
+
+	lgr.Info("Test Passed: Public dns + domain filter")
+	return nil
+}
+
+// Redeploys the public external dns excluding a subdomain of the public zone. A hostname in the zone is still
+// published while a hostname under the excluded subdomain is skipped
+var ExcludeDomainsTest = func(ctx context.Context, in infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + exclude domains test")
+
+	excludeOpt := publicOnly(func(c *pkgManifests.ExternalDnsConfig) {
+		c.ExcludeDomains = []string{excludedDomain + "." + tests.PublicZone}
+	})
+	if err := infra.DeployExternalDNS(ctx, in, excludeOpt); err != nil {
+		return fmt.Errorf("error redeploying external dns with excluded domains: %w", err)
+	}
+
+	excludedName := "app." + excludedDomain
+	svc := clients.NewExternalNameService(domainFilterServiceName, cnameTarget, clients.WithAnnotations(map[string]string{
+		tests.HostnameAnnotation: allowedName + "." + tests.PublicZone + "," + excludedName + "." + tests.PublicZone,
+	}))
+	if err := in.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying service: %w", err)
+	}
+
+	if err := validateCname(ctx, allowedName, cnameTarget, in.Cluster.GetId()); err != nil {
+		return fmt.Errorf("hostname outside excluded domain not published: %w", err)
+	}
+
+	// both hostnames are on one service so the sync that published the allowed hostname already skipped the excluded one
+	if err := ensureNoRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, excludedName, armdns.RecordType(tests.Cname), filteredTimeout); err != nil {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, excludedName, armdns.RecordType(tests.Cname), ""); delErr != nil {
+			lgr.Error("Error deleting CNAME record set " + excludedName)
+		}
+		return fmt.Errorf("hostname under excluded domain was published: %w", err)
+	}
+
+	lgr.Info("Test Passed: Public dns + exclude domains")
+	return nil
+}
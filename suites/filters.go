@@ -0,0 +1,163 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	pkgManifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	filterMatchServiceName = "nginx-svc-filter-match"
+	filterSkipServiceName  = "nginx-svc-filter-skip"
+	filterMatchName        = "filter-match"
+	filterSkipName         = "filter-skip"
+
+	filterAnnotationKey = "e2e.external-dns/publish"
+	filterLabelKey      = "e2e.external-dns/shard"
+	// unwatchedNamespace holds services external dns shouldn't see when scoped to kube-system
+	unwatchedNamespace = "e2e-unwatched"
+
+	// filteredTimeout is the number of seconds to keep checking for a filtered record once the matching record exists.
+	// Both services are deployed together so the sync that published the match has already skipped the other service
+	filteredTimeout time.Duration = 30
+)
+
+// filterCase scopes the public external dns deployment and provides a service inside and a service outside that scope
+type filterCase struct {
+	name string
+	opt  infra.ExternalDnsOpt
+	// objects returns everything to deploy for the case, the matching and skipped services last
+	objects func(zoneName string) []client.Object
+}
+
+var filterCases = []filterCase{
+	{
+		name: "annotation filter",
+		opt: func(c *pkgManifests.ExternalDnsConfig) {
+			c.AnnotationFilter = filterAnnotationKey + "=true"
+		},
+		objects: func(zoneName string) []client.Object {
+			return []client.Object{
+				clients.NewExternalNameService(filterMatchServiceName, cnameTarget, clients.WithAnnotations(map[string]string{
+					tests.HostnameAnnotation: filterMatchName + "." + zoneName,
+					filterAnnotationKey:      "true",
+				})),
+				clients.NewExternalNameService(filterSkipServiceName, cnameTarget, clients.WithAnnotations(map[string]string{
+					tests.HostnameAnnotation: filterSkipName + "." + zoneName,
+				})),
+			}
+		},
+	},
+	{
+		name: "label filter",
+		opt: func(c *pkgManifests.ExternalDnsConfig) {
+			c.LabelFilter = filterLabelKey + "=a"
+		},
+		objects: func(zoneName string) []client.Object {
+			return []client.Object{
+				clients.NewExternalNameService(filterMatchServiceName, cnameTarget,
+					clients.WithAnnotations(map[string]string{tests.HostnameAnnotation: filterMatchName + "." + zoneName}),
+					clients.WithLabels(map[string]string{filterLabelKey: "a"}),
+				),
+				clients.NewExternalNameService(filterSkipServiceName, cnameTarget,
+					clients.WithAnnotations(map[string]string{tests.HostnameAnnotation: filterSkipName + "." + zoneName}),
+					clients.WithLabels(map[string]string{filterLabelKey: "b"}),
+				),
+			}
+		},
+	},
+	{
+		name: "namespace scope",
+		opt: func(c *pkgManifests.ExternalDnsConfig) {
+			c.Namespace = "kube-system"
+		},
+		objects: func(zoneName string) []client.Object {
+			return []client.Object{
+				clients.NewNamespace(unwatchedNamespace),
+				clients.NewExternalNameService(filterMatchServiceName, cnameTarget, clients.WithAnnotations(map[string]string{
+					tests.HostnameAnnotation: filterMatchName + "." + zoneName,
+				})),
+				clients.NewExternalNameService(filterSkipServiceName, cnameTarget,
+					clients.WithAnnotations(map[string]string{tests.HostnameAnnotation: filterSkipName + "." + zoneName}),
+					clients.WithNamespace(unwatchedNamespace),
+				),
+			}
+		},
+	},
+}
+
+// Tests that scoping flags limit which services external dns publishes. Each test redeploys the public
+// external dns with the scope and restores the default deployment afterwards
+func filtersSuite(in infra.Provisioned) []test {
+	var ret []test
+	for _, c := range filterCases {
+		func(c filterCase) {
+			ret = append(ret, test{
+				name:     "public DNS + " + c.name,
+				requires: []capability{publicDnsCapability},
+				run: func(ctx context.Context) error {
+					lgr := logger.FromContext(ctx)
+					err := FilterTest(ctx, in, c)
+					tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, filterMatchServiceName)
+					tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, filterSkipServiceName)
+					tests.DeleteNamespace(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, unwatchedNamespace)
+					if restoreErr := infra.DeployExternalDNS(ctx, in); restoreErr != nil {
+						return fmt.Errorf("restoring external dns: %w", restoreErr)
+					}
+					if err != nil {
+						return err
+					}
+					lgr.Info("\n ======== Public Dns " + c.name + " test finished successfully, restoring external dns ======== \n")
+					return nil
+				},
+			})
+		}(c)
+	}
+	return ret
+}
+
+var FilterTest = func(ctx context.Context, in infra.Provisioned, c filterCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + " + c.name + " test")
+
+	if err := infra.DeployExternalDNS(ctx, in, publicOnly(c.opt)); err != nil {
+		return fmt.Errorf("error redeploying external dns with %s: %w", c.name, err)
+	}
+
+	if err := in.Cluster.Deploy(ctx, c.objects(tests.PublicZone)); err != nil {
+		return fmt.Errorf("error deploying services: %w", err)
+	}
+
+	if err := validateCname(ctx, filterMatchName, cnameTarget, in.Cluster.GetId()); err != nil {
+		return fmt.Errorf("service matching %s not published: %w", c.name, err)
+	}
+
+	err := ensureNoRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, filterSkipName, armdns.RecordType(tests.Cname), filteredTimeout)
+	if err != nil {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, filterSkipName, armdns.RecordType(tests.Cname), ""); delErr != nil {
+			lgr.Error("Error deleting CNAME record set " + filterSkipName)
+		}
+		return fmt.Errorf("service outside %s was published: %w", c.name, err)
+	}
+
+	lgr.Info("Test Passed: Public dns + " + c.name)
+	return nil
+}
+
+// Returns opt limited to the public external dns deployment
+func publicOnly(opt infra.ExternalDnsOpt) infra.ExternalDnsOpt {
+	return func(c *pkgManifests.ExternalDnsConfig) {
+		if c.Provider == pkgManifests.PublicProvider {
+			opt(c)
+		}
+	}
+}
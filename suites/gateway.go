@@ -0,0 +1,151 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	publicRouteName  = "httproute-public"
+	privateRouteName = "httproute-private"
+
+	// gatewayAddressTimeout is the number of seconds to wait for the gateway load balancer to get an ip
+	gatewayAddressTimeout = 300
+)
+
+// Tests using the gateway-httproute source, records point at the address in the status of the gateway the route is attached to
+func gatewaySuite(in infra.Provisioned) []test {
+	return []test{
+		{
+			name:     "public DNS + gateway httproute",
+			requires: []capability{publicDnsCapability, gatewayCapability, ipv4PrimaryCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := HTTPRouteTest(ctx, in)
+				tests.DeleteHTTPRoute(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, publicRouteName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns httproute test finished successfully, deleting route ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "private DNS + gateway httproute",
+			requires: []capability{linkedVnetCapability, gatewayCapability, ipv4PrimaryCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := PrivateHTTPRouteTest(ctx, in)
+				tests.DeleteHTTPRoute(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, privateRouteName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Private Dns httproute test finished successfully, deleting route ======== \n")
+				return nil
+			},
+		},
+	}
+}
+
+var HTTPRouteTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + gateway httproute test")
+
+	ip, err := gatewayAddress(ctx, infra)
+	if err != nil {
+		return err
+	}
+
+	relativeNames := []string{"route", "route-second"}
+	route := clients.NewHTTPRoute(publicRouteName, infra.GatewayName, hostnames(relativeNames, tests.PublicZone))
+	if err := infra.Cluster.Deploy(ctx, []client.Object{route}); err != nil {
+		return fmt.Errorf("error deploying httproute: %w", err)
+	}
+
+	for _, relativeName := range relativeNames {
+		if _, err = waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, relativeName, armdns.RecordTypeA, recordTimeout, aRecordCheck(ip)); err != nil {
+			err = fmt.Errorf("httproute A record set %s not created in Azure DNS: %w", relativeName, err)
+			break
+		}
+	}
+
+	for _, relativeName := range relativeNames {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, relativeName, armdns.RecordTypeA, ""); delErr != nil && !tests.IsNotFound(delErr) {
+			lgr.Error("Error deleting A record set " + relativeName)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + gateway httproute")
+	return nil
+}
+
+var PrivateHTTPRouteTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + gateway httproute test")
+
+	ip, err := gatewayAddress(ctx, infra)
+	if err != nil {
+		return err
+	}
+
+	relativeNames := []string{"route", "route-second"}
+	route := clients.NewHTTPRoute(privateRouteName, infra.GatewayName, hostnames(relativeNames, tests.PrivateZone))
+	if err := infra.Cluster.Deploy(ctx, []client.Object{route}); err != nil {
+		return fmt.Errorf("error deploying httproute: %w", err)
+	}
+
+	for _, relativeName := range relativeNames {
+		if _, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, relativeName, armprivatedns.RecordTypeA, recordTimeout, privateARecordCheck(ip)); err != nil {
+			err = fmt.Errorf("httproute A record set %s not created in Azure Private DNS: %w", relativeName, err)
+			break
+		}
+	}
+
+	for _, relativeName := range relativeNames {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, relativeName, "", armprivatedns.RecordTypeA); delErr != nil && !tests.IsNotFound(delErr) {
+			lgr.Error("Error deleting private A record set " + relativeName)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Private dns + gateway httproute")
+	return nil
+}
+
+// Returns the address of the gateway routes in infra attach to
+func gatewayAddress(ctx context.Context, infra infra.Provisioned) (string, error) {
+	if infra.GatewayName == "" {
+		return "", fmt.Errorf("gateway was not provisioned for this infrastructure")
+	}
+
+	ip, err := tests.WaitForGatewayAddress(ctx, gatewayAddressTimeout, tests.SubId, tests.ResourceGroup, *tests.ClusterName, infra.GatewayName)
+	if err != nil {
+		return "", fmt.Errorf("getting gateway address: %w", err)
+	}
+	return ip, nil
+}
+
+// Returns the fully qualified hostnames for relativeNames in zoneName
+func hostnames(relativeNames []string, zoneName string) []string {
+	ret := make([]string, len(relativeNames))
+	for i, relativeName := range relativeNames {
+		ret[i] = relativeName + "." + zoneName
+	}
+	return ret
+}
@@ -0,0 +1,143 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+// hostnameCase maps relative names under a zone to the record sets external dns should create for them
+type hostnameCase struct {
+	name string
+	// relative names joined with the zone name to form the hostname annotation
+	relativeNames []string
+}
+
+var hostnameCases = []hostnameCase{
+	{name: "subdomain", relativeNames: []string{"app"}},
+	{name: "multiple hostnames", relativeNames: []string{"first", "second"}},
+	{name: "nested subdomain", relativeNames: []string{"a.b"}},
+	{name: "wildcard", relativeNames: []string{"*"}},
+}
+
+// Returns the comma separated hostname annotation value for c in zoneName
+func (c hostnameCase) hostnames(zoneName string) string {
+	return strings.Join(hostnames(c.relativeNames, zoneName), ",")
+}
+
+// Tests that hostnames below the zone apex are published as the correct relative record sets
+func hostnamesSuite(in infra.Provisioned) []test {
+	var ret []test
+	for _, c := range hostnameCases {
+		func(c hostnameCase) {
+			ret = append(ret,
+				test{
+					name:     "public DNS + " + c.name,
+					requires: []capability{publicDnsCapability},
+					run: func(ctx context.Context) error {
+						lgr := logger.FromContext(ctx)
+						err := PublicHostnameTest(ctx, in, c)
+						tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+						if err != nil {
+							return err
+						}
+						lgr.Info("\n ======== Public Dns " + c.name + " test finished successfully, clearing service annotations ======== \n")
+						return nil
+					},
+				},
+				test{
+					name:     "private DNS + " + c.name,
+					requires: []capability{linkedVnetCapability},
+					run: func(ctx context.Context) error {
+						lgr := logger.FromContext(ctx)
+						err := PrivateHostnameTest(ctx, in, c)
+						tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+						if err != nil {
+							return err
+						}
+						lgr.Info("\n ======== Private Dns " + c.name + " test finished successfully, clearing service annotations ======== \n")
+						return nil
+					},
+				},
+			)
+		}(c)
+	}
+	return ret
+}
+
+var PublicHostnameTest = func(ctx context.Context, infra infra.Provisioned, c hostnameCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + " + c.name + " test")
+
+	annotationMap := map[string]string{
+		tests.HostnameAnnotation: c.hostnames(tests.PublicZone),
+	}
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, annotationMap); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+
+	ip := tests.Ipv4Service.Status.LoadBalancer.Ingress[0].IP
+	var err error
+	for _, relativeName := range c.relativeNames {
+		if _, err = waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, relativeName, armdns.RecordTypeA, recordTimeout, aRecordCheck(ip)); err != nil {
+			err = fmt.Errorf("%s A record set %s not created in Azure DNS: %w", c.name, relativeName, err)
+			break
+		}
+	}
+
+	// clean up every record set even if only some were created
+	for _, relativeName := range c.relativeNames {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, relativeName, armdns.RecordTypeA, ""); delErr != nil && !tests.IsNotFound(delErr) {
+			lgr.Error("Error deleting A record set " + relativeName)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + " + c.name)
+	return nil
+}
+
+var PrivateHostnameTest = func(ctx context.Context, infra infra.Provisioned, c hostnameCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + " + c.name + " test")
+
+	annotationMap := map[string]string{
+		tests.HostnameAnnotation: c.hostnames(tests.PrivateZone),
+	}
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, annotationMap); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+
+	ip := tests.Ipv4Service.Status.LoadBalancer.Ingress[0].IP
+	var err error
+	for _, relativeName := range c.relativeNames {
+		if _, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, relativeName, armprivatedns.RecordTypeA, recordTimeout, privateARecordCheck(ip)); err != nil {
+			err = fmt.Errorf("%s A record set %s not created in Azure Private DNS: %w", c.name, relativeName, err)
+			break
+		}
+	}
+
+	// clean up every record set even if only some were created
+	for _, relativeName := range c.relativeNames {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, relativeName, "", armprivatedns.RecordTypeA); delErr != nil && !tests.IsNotFound(delErr) {
+			lgr.Error("Error deleting private A record set " + relativeName)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Private dns + " + c.name)
+	return nil
+}
@@ -0,0 +1,138 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	publicIngressName   = "ingress-public"
+	internalIngressName = "ingress-internal"
+)
+
+// Tests using the ingress source, records point at the load balancer of the ingress controller serving the ingress
+func ingressSuite(in infra.Provisioned) []test {
+	return []test{
+		{
+			name:     "public DNS + ingress",
+			requires: []capability{publicDnsCapability, ingressCapability, ipv4PrimaryCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := IngressTest(ctx, in)
+				tests.DeleteIngress(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, publicIngressName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns ingress test finished successfully, deleting ingress ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "private DNS + internal ingress",
+			requires: []capability{linkedVnetCapability, ingressCapability, ipv4PrimaryCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := PrivateIngressTest(ctx, in)
+				tests.DeleteIngress(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, internalIngressName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Private Dns internal ingress test finished successfully, deleting ingress ======== \n")
+				return nil
+			},
+		},
+	}
+}
+
+var IngressTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + ingress test")
+
+	ip, err := loadBalancerIp(tests.IngressService)
+	if err != nil {
+		return fmt.Errorf("getting public ingress controller ip: %w", err)
+	}
+
+	ruleName, tlsName := "ingress", "ingress-tls"
+	ing := clients.NewIngress(publicIngressName, clients.PublicIngressClass, []string{ruleName + "." + tests.PublicZone}, []string{tlsName + "." + tests.PublicZone})
+	if err := infra.Cluster.Deploy(ctx, []client.Object{ing}); err != nil {
+		return fmt.Errorf("error deploying ingress: %w", err)
+	}
+
+	for _, relativeName := range []string{ruleName, tlsName} {
+		if _, err = waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, relativeName, armdns.RecordTypeA, recordTimeout, aRecordCheck(ip)); err != nil {
+			err = fmt.Errorf("ingress A record set %s not created in Azure DNS: %w", relativeName, err)
+			break
+		}
+	}
+
+	for _, relativeName := range []string{ruleName, tlsName} {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, relativeName, armdns.RecordTypeA, ""); delErr != nil {
+			lgr.Error("Error deleting A record set " + relativeName)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + ingress")
+	return nil
+}
+
+var PrivateIngressTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + internal ingress test")
+
+	ip, err := loadBalancerIp(tests.InternalIngressService)
+	if err != nil {
+		return fmt.Errorf("getting internal ingress controller ip: %w", err)
+	}
+
+	ruleName, tlsName := "ingress", "ingress-tls"
+	ing := clients.NewIngress(internalIngressName, clients.InternalIngressClass, []string{ruleName + "." + tests.PrivateZone}, []string{tlsName + "." + tests.PrivateZone})
+	if err := infra.Cluster.Deploy(ctx, []client.Object{ing}); err != nil {
+		return fmt.Errorf("error deploying ingress: %w", err)
+	}
+
+	for _, relativeName := range []string{ruleName, tlsName} {
+		if _, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, relativeName, armprivatedns.RecordTypeA, recordTimeout, privateARecordCheck(ip)); err != nil {
+			err = fmt.Errorf("ingress A record set %s not created in Azure Private DNS: %w", relativeName, err)
+			break
+		}
+	}
+
+	for _, relativeName := range []string{ruleName, tlsName} {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, relativeName, "", armprivatedns.RecordTypeA); delErr != nil {
+			lgr.Error("Error deleting private A record set " + relativeName)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Private dns + internal ingress")
+	return nil
+}
+
+// Returns the first load balancer ip of svc
+func loadBalancerIp(svc *corev1.Service) (string, error) {
+	if svc == nil {
+		return "", fmt.Errorf("service was not provisioned for this infrastructure")
+	}
+	if len(svc.Status.LoadBalancer.Ingress) == 0 || svc.Status.LoadBalancer.Ingress[0].IP == "" {
+		return "", fmt.Errorf("service %s has no load balancer ip", svc.Name)
+	}
+	return svc.Status.LoadBalancer.Ingress[0].IP, nil
+}
@@ -0,0 +1,204 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	pkgManifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const nestedServiceName = "nginx-svc-nested"
+
+// nestedRecord is a CNAME record set in either the public zone or the child zone nested under it
+type nestedRecord struct {
+	child        bool
+	relativeName string
+}
+
+// Returns the zone r lives in
+func (r nestedRecord) zoneName() string {
+	if r.child {
+		return tests.NestedZone
+	}
+	return tests.PublicZone
+}
+
+// nestedZoneCase publishes hostnames that fall under both the public zone and its child zone and checks which zone
+// external dns writes each of them into
+type nestedZoneCase struct {
+	name         string
+	zoneIdFilter zoneIdFilter
+	// hostnames are relative to the public zone
+	hostnames []string
+	published []nestedRecord
+	skipped   []nestedRecord
+}
+
+// zoneIdFilter is the zone the public external dns is redeployed to select with --zone-id-filter
+type zoneIdFilter int
+
+const (
+	// noZoneIdFilter keeps the default deployment which passes the public zone to --domain-filter
+	noZoneIdFilter zoneIdFilter = iota
+	parentZoneIdFilter
+	childZoneIdFilter
+)
+
+var nestedZoneCases = []nestedZoneCase{
+	{
+		// the domain filter on the parent also matches the child, the most specific zone wins
+		name:      "domain filter",
+		hostnames: []string{"app.sub"},
+		published: []nestedRecord{{child: true, relativeName: "app"}},
+		skipped:   []nestedRecord{{child: false, relativeName: "app.sub"}},
+	},
+	{
+		name:         "zone id filter on child zone",
+		zoneIdFilter: childZoneIdFilter,
+		hostnames:    []string{"app.sub", "app"},
+		published:    []nestedRecord{{child: true, relativeName: "app"}},
+		skipped:      []nestedRecord{{child: false, relativeName: "app"}, {child: false, relativeName: "app.sub"}},
+	},
+	{
+		// with the child zone filtered out the parent is the most specific zone left
+		name:         "zone id filter on parent zone",
+		zoneIdFilter: parentZoneIdFilter,
+		hostnames:    []string{"app.sub"},
+		published:    []nestedRecord{{child: false, relativeName: "app.sub"}},
+		skipped:      []nestedRecord{{child: true, relativeName: "app"}},
+	},
+}
+
+// Tests zone matching when a public zone and a zone nested under it are both available to external dns.
+// Zone id filter cases redeploy the public external dns and restore the default deployment afterwards
+func nestedZonesSuite(in infra.Provisioned) []test {
+	var ret []test
+	for _, c := range nestedZoneCases {
+		func(c nestedZoneCase) {
+			ret = append(ret, test{
+				name:     "public DNS + nested zones + " + c.name,
+				requires: []capability{publicDnsCapability, nestedZoneCapability},
+				run: func(ctx context.Context) error {
+					lgr := logger.FromContext(ctx)
+					err := NestedZoneTest(ctx, in, c)
+					tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, nestedServiceName)
+					if c.zoneIdFilter != noZoneIdFilter {
+						if restoreErr := infra.DeployExternalDNS(ctx, in); restoreErr != nil {
+							return fmt.Errorf("restoring external dns: %w", restoreErr)
+						}
+					}
+					if err != nil {
+						return err
+					}
+					lgr.Info("\n ======== Public Dns nested zones " + c.name + " test finished successfully, deleting service ======== \n")
+					return nil
+				},
+			})
+		}(c)
+	}
+	return ret
+}
+
+var NestedZoneTest = func(ctx context.Context, in infra.Provisioned, c nestedZoneCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + nested zones + " + c.name + " test")
+
+	if tests.NestedZone == "" {
+		return fmt.Errorf("nested zone was not provisioned for this infrastructure")
+	}
+
+	if c.zoneIdFilter != noZoneIdFilter {
+		selected := nestedRecord{child: c.zoneIdFilter == childZoneIdFilter}.zoneName()
+		zoneId, err := zoneResourceId(in, selected)
+		if err != nil {
+			return err
+		}
+
+		// ids Azure returned for the zone rather than ones built from names, external dns compares them case sensitively
+		zoneIdOpt := publicOnly(func(c *pkgManifests.ExternalDnsConfig) {
+			c.DnsZoneResourceIDs = []string{zoneId}
+			c.ZoneIdFilter = true
+		})
+		if err := infra.DeployExternalDNS(ctx, in, zoneIdOpt); err != nil {
+			return fmt.Errorf("error redeploying external dns with zone id filter: %w", err)
+		}
+	}
+
+	hostnames := make([]string, len(c.hostnames))
+	for i, h := range c.hostnames {
+		hostnames[i] = h + "." + tests.PublicZone
+	}
+	svc := clients.NewExternalNameService(nestedServiceName, cnameTarget, clients.WithAnnotations(map[string]string{
+		tests.HostnameAnnotation: strings.Join(hostnames, ","),
+	}))
+	if err := in.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying service: %w", err)
+	}
+
+	err := validateNestedRecords(ctx, c, in.Cluster.GetId())
+
+	for _, r := range append(append([]nestedRecord{}, c.published...), c.skipped...) {
+		deleteNestedRecord(ctx, r)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + nested zones + " + c.name)
+	return nil
+}
+
+// Waits for every published record and its ownership TXT record, then checks none of the skipped records were written.
+// All hostnames are on one service so the sync that published the records has already considered the skipped ones
+func validateNestedRecords(ctx context.Context, c nestedZoneCase, owner string) error {
+	for _, r := range c.published {
+		if _, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, r.zoneName(), r.relativeName, armdns.RecordType(tests.Cname), recordTimeout, cnameRecordCheck(cnameTarget)); err != nil {
+			return fmt.Errorf("CNAME record %s not created in zone %s: %w", r.relativeName, r.zoneName(), err)
+		}
+
+		txtName := ownershipRecordName(r.relativeName, tests.Cname)
+		if _, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, r.zoneName(), txtName, armdns.RecordTypeTXT, recordTimeout, ownershipCheck(owner)); err != nil {
+			return fmt.Errorf("ownership TXT record %s not created in zone %s: %w", txtName, r.zoneName(), err)
+		}
+	}
+
+	for _, r := range c.skipped {
+		if err := ensureNoRecordSet(ctx, tests.ResourceGroup, tests.SubId, r.zoneName(), r.relativeName, armdns.RecordType(tests.Cname), filteredTimeout); err != nil {
+			return fmt.Errorf("CNAME record written into the wrong zone %s: %w", r.zoneName(), err)
+		}
+	}
+
+	return nil
+}
+
+// Deletes the CNAME record set r and its ownership TXT record if external dns created them
+func deleteNestedRecord(ctx context.Context, r nestedRecord) {
+	lgr := logger.FromContext(ctx)
+
+	if err := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, r.zoneName(), r.relativeName, armdns.RecordType(tests.Cname), ""); err != nil && !tests.IsNotFound(err) {
+		lgr.Error("Error deleting CNAME record set " + r.relativeName + " in zone " + r.zoneName())
+	}
+	txtName := ownershipRecordName(r.relativeName, tests.Cname)
+	if err := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, r.zoneName(), txtName, armdns.RecordTypeTXT, ""); err != nil && !tests.IsNotFound(err) {
+		lgr.Error("Error deleting TXT record set " + txtName + " in zone " + r.zoneName())
+	}
+}
+
+// Returns the resource id Azure assigned to the provisioned public zone zoneName
+func zoneResourceId(in infra.Provisioned, zoneName string) (string, error) {
+	for _, z := range in.Zones {
+		if z.GetName() == zoneName {
+			return z.GetId(), nil
+		}
+	}
+	return "", fmt.Errorf("zone %s was not provisioned for this infrastructure", zoneName)
+}
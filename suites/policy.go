@@ -0,0 +1,139 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	pkgManifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	policyServiceName = "nginx-svc-policy"
+	policyName        = "policy"
+	// updatedCnameTarget replaces cnameTarget on the service for the update step
+	updatedCnameTarget = "e2e-updated-target.example.com"
+)
+
+// policyCase is the expected outcome of each step under a policy. Every policy creates records,
+// a step that isn't applied must leave the record exactly as the previous step did
+type policyCase struct {
+	policy  pkgManifests.Policy
+	updates bool
+	deletes bool
+}
+
+var policyCases = []policyCase{
+	{policy: pkgManifests.SyncPolicy, updates: true, deletes: true},
+	{policy: pkgManifests.UpsertOnlyPolicy, updates: true, deletes: false},
+	{policy: pkgManifests.CreateOnlyPolicy, updates: false, deletes: false},
+}
+
+// Tests creating, updating and deleting a record under every policy. Each test redeploys the public
+// external dns with the policy and restores the default deployment afterwards
+func policySuite(in infra.Provisioned) []test {
+	var ret []test
+	for _, c := range policyCases {
+		func(c policyCase) {
+			ret = append(ret, test{
+				name:     "public DNS + " + string(c.policy) + " policy",
+				requires: []capability{publicDnsCapability},
+				run: func(ctx context.Context) error {
+					lgr := logger.FromContext(ctx)
+					err := PolicyTest(ctx, in, c)
+					tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, policyServiceName)
+					deletePolicyRecords(ctx)
+					if restoreErr := infra.DeployExternalDNS(ctx, in); restoreErr != nil {
+						return fmt.Errorf("restoring external dns: %w", restoreErr)
+					}
+					if err != nil {
+						return err
+					}
+					lgr.Info("\n ======== Public Dns " + string(c.policy) + " policy test finished successfully, restoring external dns ======== \n")
+					return nil
+				},
+			})
+		}(c)
+	}
+	return ret
+}
+
+var PolicyTest = func(ctx context.Context, in infra.Provisioned, c policyCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + " + string(c.policy) + " policy test")
+
+	policyOpt := publicOnly(func(dnsConfig *pkgManifests.ExternalDnsConfig) {
+		dnsConfig.Policy = c.policy
+	})
+	if err := infra.DeployExternalDNS(ctx, in, policyOpt); err != nil {
+		return fmt.Errorf("error redeploying external dns with %s policy: %w", c.policy, err)
+	}
+
+	recordType := armdns.RecordType(tests.Cname)
+	hostname := map[string]string{tests.HostnameAnnotation: policyName + "." + tests.PublicZone}
+
+	// create
+	svc := clients.NewExternalNameService(policyServiceName, cnameTarget, clients.WithAnnotations(hostname))
+	if err := in.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying service: %w", err)
+	}
+	if _, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, policyName, recordType, recordTimeout, cnameRecordCheck(cnameTarget)); err != nil {
+		return fmt.Errorf("CNAME record not created under %s policy: %w", c.policy, err)
+	}
+	txtName := ownershipRecordName(policyName, tests.Cname)
+	if _, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, txtName, armdns.RecordTypeTXT, recordTimeout, ownershipCheck(in.Cluster.GetId())); err != nil {
+		return fmt.Errorf("ownership TXT record not created under %s policy: %w", c.policy, err)
+	}
+
+	// update
+	svc = clients.NewExternalNameService(policyServiceName, updatedCnameTarget, clients.WithAnnotations(hostname))
+	if err := in.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error updating service: %w", err)
+	}
+	currentTarget := cnameTarget
+	if c.updates {
+		if _, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, policyName, recordType, recordTimeout, cnameRecordCheck(updatedCnameTarget)); err != nil {
+			return fmt.Errorf("CNAME record not updated under %s policy: %w", c.policy, err)
+		}
+		currentTarget = updatedCnameTarget
+	} else if err := ensureRecordSetUnchanged(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, policyName, recordType, recordTimeout, cnameRecordCheck(cnameTarget)); err != nil {
+		return fmt.Errorf("CNAME record updated under %s policy: %w", c.policy, err)
+	}
+
+	// delete
+	if err := tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, policyServiceName); err != nil {
+		return fmt.Errorf("error deleting service: %w", err)
+	}
+	if c.deletes {
+		if err := waitForRecordSetDeleted(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, policyName, recordType, recordTimeout); err != nil {
+			return fmt.Errorf("CNAME record not deleted under %s policy: %w", c.policy, err)
+		}
+		if err := waitForRecordSetDeleted(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, txtName, armdns.RecordTypeTXT, recordTimeout); err != nil {
+			return fmt.Errorf("ownership TXT record not deleted under %s policy: %w", c.policy, err)
+		}
+	} else if err := ensureRecordSetUnchanged(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, policyName, recordType, recordTimeout, cnameRecordCheck(currentTarget)); err != nil {
+		return fmt.Errorf("CNAME record deleted under %s policy: %w", c.policy, err)
+	}
+
+	lgr.Info("Test Passed: Public dns + " + string(c.policy) + " policy")
+	return nil
+}
+
+// Deletes the CNAME record set and its ownership TXT record left behind by policies that don't delete
+func deletePolicyRecords(ctx context.Context) {
+	lgr := logger.FromContext(ctx)
+
+	if err := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, policyName, armdns.RecordType(tests.Cname), ""); err != nil && !tests.IsNotFound(err) {
+		lgr.Error("Error deleting CNAME record set " + policyName)
+	}
+	txtName := ownershipRecordName(policyName, tests.Cname)
+	if err := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, txtName, armdns.RecordTypeTXT, ""); err != nil && !tests.IsNotFound(err) {
+		lgr.Error("Error deleting TXT record set " + txtName)
+	}
+}
@@ -3,48 +3,60 @@ package suites
 import (
 	"context"
 	"fmt"
-	"log"
-	"strings"
+	"net"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	corev1 "k8s.io/api/core/v1"
 
-	"github.com/Azure/azure-provider-external-dns-e2e/clients"
 	"github.com/Azure/azure-provider-external-dns-e2e/infra"
 	"github.com/Azure/azure-provider-external-dns-e2e/logger"
 	"github.com/Azure/azure-provider-external-dns-e2e/tests"
 )
 
+// loadBalancerTimeout is the number of seconds to wait for azure to move a service between a public and an internal load balancer
+const loadBalancerTimeout time.Duration = 300
+
 // Tests using the provisioned private dns zone for creating A and AAAA records
 func privateDnsSuite(in infra.Provisioned) []test {
 	return []test{
 		{
-			name: "private DNS +  A Record",
+			name:     "private DNS +  A Record",
+			requires: []capability{linkedVnetCapability},
 			run: func(ctx context.Context) error {
 				lgr := logger.FromContext(ctx)
 				if err := PrivateARecordTest(ctx, in); err != nil {
-					tests.ClearAnnotations(ctx, in.SubscriptionId, *tests.ClusterName, in.ResourceGroup.GetName(), tests.Ipv4Service.Name)
+					restorePublicService(ctx, in.Ipv4ServiceName, &tests.Ipv4Service)
 					return err
 				}
 				lgr.Info("\n ======== Private Dns ipv4 test finished successfully, clearing service annotations ======== \n")
-				tests.ClearAnnotations(ctx, in.SubscriptionId, *tests.ClusterName, in.ResourceGroup.GetName(), tests.Ipv4Service.Name)
-				return nil
+				return restorePublicService(ctx, in.Ipv4ServiceName, &tests.Ipv4Service)
+			},
+		},
+		{
+			name:     "private DNS +  A Record + internal load balancer subnet",
+			requires: []capability{linkedVnetCapability, internalLbSubnetCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				if err := PrivateInternalSubnetTest(ctx, in); err != nil {
+					restorePublicService(ctx, in.Ipv4ServiceName, &tests.Ipv4Service)
+					return err
+				}
+				lgr.Info("\n ======== Private Dns internal subnet test finished successfully, clearing service annotations ======== \n")
+				return restorePublicService(ctx, in.Ipv4ServiceName, &tests.Ipv4Service)
 			},
 		},
 		{
-			name: "private DNS +  AAAA Record",
+			name:     "private DNS +  AAAA Record",
+			requires: []capability{linkedVnetCapability, ipv6Capability},
 			run: func(ctx context.Context) error {
 				lgr := logger.FromContext(ctx)
 				if err := PrivateAAAATest(ctx, in); err != nil {
-					tests.ClearAnnotations(ctx, in.SubscriptionId, *tests.ClusterName, in.ResourceGroup.GetName(), tests.Ipv4Service.Name)
-					tests.ClearAnnotations(ctx, in.SubscriptionId, *tests.ClusterName, in.ResourceGroup.GetName(), tests.Ipv6Service.Name)
+					restorePublicService(ctx, in.Ipv6ServiceName, &tests.Ipv6Service)
 					return err
 				}
 				lgr.Info("\n ======== Private Dns ipv6 test finished successfully, clearing service annotations ======== \n ")
-				tests.ClearAnnotations(ctx, in.SubscriptionId, *tests.ClusterName, in.ResourceGroup.GetName(), tests.Ipv4Service.Name)
-				tests.ClearAnnotations(ctx, in.SubscriptionId, *tests.ClusterName, in.ResourceGroup.GetName(), tests.Ipv6Service.Name)
-				return nil
+				return restorePublicService(ctx, in.Ipv6ServiceName, &tests.Ipv6Service)
 			},
 		},
 	}
@@ -54,28 +66,45 @@ var PrivateARecordTest = func(ctx context.Context, infra infra.Provisioned) erro
 	lgr := logger.FromContext(ctx)
 	lgr.Info("starting test")
 
-	ipv4ServiceName := infra.Ipv4ServiceName
+	if err := validateInternalService(ctx, infra.Ipv4ServiceName, "", net.IP.IsPrivate); err != nil {
+		return err
+	}
 
-	err := tests.PrivateDnsAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, ipv4ServiceName)
-	if err != nil {
-		lgr.Error("Error annotating service with private dns annotations", err)
-		return fmt.Errorf("error: %s", err)
+	lgr.Info("Test Passed: Private Dns + A record test successfully")
+	return nil
+}
+
+// Places the internal load balancer in the dedicated subnet with the azure-load-balancer-internal-subnet annotation
+var PrivateInternalSubnetTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + internal load balancer subnet test")
+
+	if infra.InternalLbSubnetName == "" {
+		return fmt.Errorf("internal load balancer subnet was not provisioned for this infrastructure")
 	}
 
-	//Validating Records
-	err = validatePrivateRecords(ctx, armprivatedns.RecordTypeA, tests.ResourceGroup, tests.SubId, *tests.ClusterName, tests.PrivateZone, 150, tests.Ipv4Service.Status.LoadBalancer.Ingress[0].IP)
-	if err != nil {
-		return fmt.Errorf("%s Private Record not created in Azure DNS", armdns.RecordTypeA)
-	} else {
-		lgr.Info("Test Passed: Private Dns + A record test successfully")
+	var subnets []*net.IPNet
+	for _, prefix := range infra.InternalLbSubnetPrefixes {
+		_, subnet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return fmt.Errorf("parsing internal load balancer subnet prefix: %w", err)
+		}
+		subnets = append(subnets, subnet)
+	}
+	inSubnet := func(ip net.IP) bool {
+		for _, subnet := range subnets {
+			if subnet.Contains(ip) {
+				return true
+			}
+		}
+		return false
 	}
 
-	err = tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, "", armprivatedns.RecordTypeA)
-	if err != nil {
-		lgr.Error("Error deleting AAAA record set")
-		return fmt.Errorf("error deleting AAAA record set")
+	if err := validateInternalService(ctx, infra.Ipv4ServiceName, infra.InternalLbSubnetName, inSubnet); err != nil {
+		return err
 	}
 
+	lgr.Info("Test Passed: Private Dns + internal load balancer subnet")
 	return nil
 }
 
@@ -83,96 +112,71 @@ var PrivateAAAATest = func(ctx context.Context, infra infra.Provisioned) error {
 	lgr := logger.FromContext(ctx)
 	lgr.Info("starting test")
 
-	ipv6ServiceName := infra.Ipv6ServiceName
+	if err := validateInternalService(ctx, infra.Ipv6ServiceName, "", net.IP.IsPrivate); err != nil {
+		return err
+	}
 
-	err := tests.PrivateDnsAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, ipv6ServiceName)
-	if err != nil {
+	lgr.Info("Test Passed: Private Dns + AAAA record test successfully")
+	return nil
+}
+
+// Moves serviceName behind an internal load balancer and checks both paths into the private zone: the hostname
+// annotation publishes the internal load balancer ip at the apex and internal-hostname publishes the cluster ip.
+// The record type follows the ip family of the service
+func validateInternalService(ctx context.Context, serviceName, internalSubnet string, acceptLbIp func(net.IP) bool) error {
+	lgr := logger.FromContext(ctx)
+
+	if err := tests.PrivateDnsAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, serviceName, internalSubnet); err != nil {
 		lgr.Error("Error annotating service with private dns annotations", err)
 		return fmt.Errorf("error: %s", err)
 	}
 
-	//Validating records
-	err = validatePrivateRecords(ctx, armprivatedns.RecordTypeAAAA, tests.ResourceGroup, tests.SubId, *tests.ClusterName, tests.PrivateZone, 150, tests.Ipv6Service.Status.LoadBalancer.Ingress[0].IP)
+	svc, err := tests.WaitForLoadBalancerIp(ctx, loadBalancerTimeout, tests.SubId, tests.ResourceGroup, *tests.ClusterName, serviceName, acceptLbIp)
 	if err != nil {
-		return fmt.Errorf("%s Private Record not created in Azure DNS", armdns.RecordTypeAAAA)
-	} else {
-		lgr.Info("Test Passed: Private Dns + AAAA record test successfully")
+		return fmt.Errorf("waiting for internal load balancer ip: %w", err)
 	}
-
-	//Deleting A and AAAA record sets
-	err = tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, "", armprivatedns.RecordTypeAAAA)
-	if err != nil {
-		lgr.Error("Error deleting AAAA record set")
-		return fmt.Errorf("error deleting AAAA record set")
-	}
-
-	return nil
-
-}
-
-func validatePrivateRecords(ctx context.Context, recordType armprivatedns.RecordType, rg, subscriptionId, clusterName, serviceDnsZoneName string, numSeconds time.Duration, svcIp string) error {
-	lgr := logger.FromContext(ctx)
-	lgr.Info("Checking that Record was created in Azure DNS")
+	lbIp := svc.Status.LoadBalancer.Ingress[0].IP
+	clusterIp := svc.Spec.ClusterIP
 
 	//Default 10 seconds to wait for external dns pod to start running, can be modified in the future if needed
-	err := tests.WaitForExternalDns(ctx, 10, subscriptionId, rg, clusterName, "external-dns-private")
-	if err != nil {
+	if err := tests.WaitForExternalDns(ctx, 10, tests.SubId, tests.ResourceGroup, *tests.ClusterName, "external-dns-private"); err != nil {
 		return fmt.Errorf("error waiting for ExternalDNS to start running %w", err)
 	}
 
-	cred, err := clients.GetAzCred()
-	if err != nil {
-		return fmt.Errorf("getting az credentials: %w", err)
+	recordType, check := armprivatedns.RecordTypeA, privateARecordCheck
+	if len(svc.Spec.IPFamilies) > 0 && svc.Spec.IPFamilies[0] == corev1.IPv6Protocol {
+		recordType, check = armprivatedns.RecordTypeAAAA, privateAaaaRecordCheck
 	}
 
-	clientFactory, err := armprivatedns.NewClientFactory(subscriptionId, cred, nil)
-	if err != nil {
-		log.Fatal(err)
+	if _, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, "@", recordType, recordTimeout, check(lbIp)); err != nil {
+		err = fmt.Errorf("%s private record set for internal load balancer ip %s not created: %w", recordType, lbIp, err)
+	} else if _, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, tests.InternalRelativeName, recordType, recordTimeout, check(clusterIp)); err != nil {
+		err = fmt.Errorf("%s private record set for internal-hostname cluster ip %s not created: %w", recordType, clusterIp, err)
 	}
 
-	timeout := time.Now().Add(numSeconds * time.Second)
-	var pageValue []*armprivatedns.RecordSet
-	for {
-		if time.Now().After(timeout) {
-			return fmt.Errorf("record not created within %s seconds", numSeconds)
+	for _, relativeName := range []string{"@", tests.InternalRelativeName} {
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, relativeName, "", recordType); delErr != nil && !tests.IsNotFound(delErr) {
+			lgr.Error("Error deleting private " + string(recordType) + " record set " + relativeName)
 		}
-		pager := clientFactory.NewRecordSetsClient().NewListByTypePager(rg, serviceDnsZoneName, recordType, &armprivatedns.RecordSetsClientListByTypeOptions{Top: nil,
-			Recordsetnamesuffix: nil,
-		})
-
-		if pager.More() {
-			page, err := pager.NextPage(ctx)
-			if err != nil {
-				log.Fatal("failed to advance page", err)
-				return fmt.Errorf("failed to advance page for record sets")
-			}
-			if len(page.Value) > 0 {
-				pageValue = page.Value
-				break
-			}
-
-		}
-		time.Sleep(2 * time.Second)
 	}
 
-	var ipAddr string
-
-	for _, v := range pageValue {
-		currZoneName := strings.Trim(*(v.Properties.Fqdn), ".") //removing trailing '.'
-
-		if recordType == armprivatedns.RecordTypeA {
-			ipAddr = *(v.Properties.ARecords[0].IPv4Address)
-		} else if recordType == armprivatedns.RecordTypeAAAA {
-			ipAddr = *(v.Properties.AaaaRecords[0].IPv6Address)
-		} else {
-			return fmt.Errorf("unable to match record type")
-		}
+	return err
+}
 
-		if currZoneName == serviceDnsZoneName && ipAddr == svcIp {
-			return nil
-		}
+// Clears the private dns annotations from serviceName and waits for it to get a public load balancer ip again,
+// then refreshes svc so later tests compare against the new public ip
+func restorePublicService(ctx context.Context, serviceName string, svc **corev1.Service) error {
+	if err := tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, serviceName); err != nil {
+		return fmt.Errorf("clearing annotations: %w", err)
+	}
 
+	restored, err := tests.WaitForLoadBalancerIp(ctx, loadBalancerTimeout, tests.SubId, tests.ResourceGroup, *tests.ClusterName, serviceName, func(ip net.IP) bool {
+		return !ip.IsPrivate()
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for public load balancer ip: %w", err)
 	}
 
-	return fmt.Errorf("record not created %s", recordType) //test failed
+	*svc = restored
+	return nil
 }
@@ -0,0 +1,138 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	pkgManifests "github.com/Azure/azure-provider-external-dns-e2e/pkgResources/pkgManifests"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	rbacServiceName = "nginx-svc-rbac"
+	rbacName        = "rbac"
+	// authorizationError is the error code Azure returns when the identity lacks a role, external dns logs it as is
+	authorizationError = "AuthorizationFailed"
+)
+
+// rbacCase runs external dns as the identity provisioned with access and expects records to be published only when
+// publishes is set
+type rbacCase struct {
+	access    infra.DnsAccess
+	publishes bool
+}
+
+var rbacCases = []rbacCase{
+	{access: infra.NoDnsAccess, publishes: false},
+	{access: infra.ReaderDnsAccess, publishes: false},
+	// the contributor roles at resource group scope and at zone scope with Reader on the resource group both publish,
+	// so write access can be scoped to the zones external dns manages
+	{access: infra.ResourceGroupDnsAccess, publishes: true},
+	{access: infra.ZoneDnsAccess, publishes: true},
+}
+
+// Tests which roles external dns needs by redeploying it as identities holding less than the default deployment.
+// Only runs on infrastructure with a workload identity, the other auth modes can't swap identities per deployment
+func rbacSuite(in infra.Provisioned) []test {
+	var ret []test
+	for _, c := range rbacCases {
+		func(c rbacCase) {
+			ret = append(ret, test{
+				name:     "public and private DNS + " + string(c.access) + " identity",
+				requires: []capability{publicDnsCapability, linkedVnetCapability, rbacIdentitiesCapability},
+				run: func(ctx context.Context) error {
+					lgr := logger.FromContext(ctx)
+					err := RbacTest(ctx, in, c)
+					tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, rbacServiceName)
+					deleteRbacRecords(ctx)
+					if restoreErr := infra.DeployExternalDNS(ctx, in); restoreErr != nil {
+						return fmt.Errorf("restoring external dns: %w", restoreErr)
+					}
+					if err != nil {
+						return err
+					}
+					lgr.Info("\n ======== Public and private Dns " + string(c.access) + " identity test finished successfully, restoring external dns ======== \n")
+					return nil
+				},
+			})
+		}(c)
+	}
+	return ret
+}
+
+var RbacTest = func(ctx context.Context, in infra.Provisioned, c rbacCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public and private dns + " + string(c.access) + " identity test")
+
+	identity, ok := in.RbacIdentities[c.access]
+	if !ok {
+		return fmt.Errorf("%s identity was not provisioned for this infrastructure", c.access)
+	}
+
+	identityOpt := func(dnsConfig *pkgManifests.ExternalDnsConfig) {
+		dnsConfig.ClientId = identity.GetClientId()
+	}
+	if err := infra.DeployExternalDNS(ctx, in, identityOpt); err != nil {
+		return fmt.Errorf("error redeploying external dns as %s identity: %w", c.access, err)
+	}
+
+	svc := clients.NewExternalNameService(rbacServiceName, cnameTarget, clients.WithAnnotations(map[string]string{
+		tests.HostnameAnnotation: rbacName + "." + tests.PublicZone + "," + rbacName + "." + tests.PrivateZone,
+	}))
+	if err := in.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying service: %w", err)
+	}
+
+	if c.publishes {
+		if _, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, rbacName, armdns.RecordType(tests.Cname), recordTimeout, cnameRecordCheck(cnameTarget)); err != nil {
+			return fmt.Errorf("CNAME record not created with %s identity: %w", c.access, err)
+		}
+		if _, err := waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, rbacName, armprivatedns.RecordType(tests.Cname), recordTimeout, privateCnameRecordCheck(cnameTarget)); err != nil {
+			return fmt.Errorf("private CNAME record not created with %s identity: %w", c.access, err)
+		}
+		lgr.Info("Test Passed: Public and private dns + " + string(c.access) + " identity")
+		return nil
+	}
+
+	// the error proves external dns tried to sync, so records missing afterwards weren't just not written yet
+	for _, provider := range pkgManifests.Providers {
+		if err := tests.WaitForPodLog(ctx, recordTimeout, tests.SubId, tests.ResourceGroup, *tests.ClusterName, "app="+provider.ResourceName(), authorizationError); err != nil {
+			return fmt.Errorf("%s didn't log an authorization error with %s identity: %w", provider.ResourceName(), c.access, err)
+		}
+	}
+	if err := ensureNoRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, rbacName, armdns.RecordType(tests.Cname), filteredTimeout); err != nil {
+		return fmt.Errorf("CNAME record created with %s identity: %w", c.access, err)
+	}
+	if err := ensureNoPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, rbacName, armprivatedns.RecordType(tests.Cname), filteredTimeout); err != nil {
+		return fmt.Errorf("private CNAME record created with %s identity: %w", c.access, err)
+	}
+
+	lgr.Info("Test Passed: Public and private dns + " + string(c.access) + " identity")
+	return nil
+}
+
+// Deletes the CNAME record sets and their ownership TXT records in the public and private zone if external dns created them
+func deleteRbacRecords(ctx context.Context) {
+	lgr := logger.FromContext(ctx)
+	txtName := ownershipRecordName(rbacName, tests.Cname)
+
+	if err := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, rbacName, armdns.RecordType(tests.Cname), ""); err != nil && !tests.IsNotFound(err) {
+		lgr.Error("Error deleting CNAME record set " + rbacName)
+	}
+	if err := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, txtName, armdns.RecordTypeTXT, ""); err != nil && !tests.IsNotFound(err) {
+		lgr.Error("Error deleting TXT record set " + txtName)
+	}
+	if err := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, rbacName, "", armprivatedns.RecordType(tests.Cname)); err != nil && !tests.IsNotFound(err) {
+		lgr.Error("Error deleting private CNAME record set " + rbacName)
+	}
+	if err := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, txtName, "", armprivatedns.RecordTypeTXT); err != nil && !tests.IsNotFound(err) {
+		lgr.Error("Error deleting private TXT record set " + txtName)
+	}
+}
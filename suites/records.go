@@ -0,0 +1,453 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+// recordTimeout is the number of seconds to wait for external dns to reconcile a change.
+// It covers two of the 3 minute sync intervals external dns is deployed with.
+const recordTimeout time.Duration = 400
+
+// Polls Azure DNS until the public record set exists and passes check, returns the last error seen if numSeconds pass first
+func waitForRecordSet(ctx context.Context, rg, subscriptionId, zoneName, relativeName string, recordType armdns.RecordType, numSeconds time.Duration, check func(*armdns.RecordSet) error) (*armdns.RecordSet, error) {
+	lgr := logger.FromContext(ctx).With("zone", zoneName, "recordSet", relativeName, "recordType", recordType)
+	lgr.Info("waiting for record set in Azure DNS")
+
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for {
+		recordSet, err := tests.GetRecordSet(ctx, subscriptionId, rg, zoneName, relativeName, recordType)
+		if err != nil && !tests.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err = check(recordSet); err == nil {
+				return recordSet, nil
+			}
+		}
+
+		if time.Now().After(timeout) {
+			return nil, fmt.Errorf("%s record set %s not valid within %d seconds: %w", recordType, relativeName, numSeconds, err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// Polls Azure Private DNS until the private record set exists and passes check, returns the last error seen if numSeconds pass first
+func waitForPrivateRecordSet(ctx context.Context, rg, subscriptionId, zoneName, relativeName string, recordType armprivatedns.RecordType, numSeconds time.Duration, check func(*armprivatedns.RecordSet) error) (*armprivatedns.RecordSet, error) {
+	lgr := logger.FromContext(ctx).With("zone", zoneName, "recordSet", relativeName, "recordType", recordType)
+	lgr.Info("waiting for record set in Azure Private DNS")
+
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for {
+		recordSet, err := tests.GetPrivateRecordSet(ctx, subscriptionId, rg, zoneName, relativeName, recordType)
+		if err != nil && !tests.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err = check(recordSet); err == nil {
+				return recordSet, nil
+			}
+		}
+
+		if time.Now().After(timeout) {
+			return nil, fmt.Errorf("%s private record set %s not valid within %d seconds: %w", recordType, relativeName, numSeconds, err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// Waits numSeconds and returns an error if the public record set appears at any point
+func ensureNoRecordSet(ctx context.Context, rg, subscriptionId, zoneName, relativeName string, recordType armdns.RecordType, numSeconds time.Duration) error {
+	lgr := logger.FromContext(ctx).With("zone", zoneName, "recordSet", relativeName, "recordType", recordType)
+	lgr.Info("ensuring record set is not created in Azure DNS")
+
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for time.Now().Before(timeout) {
+		_, err := tests.GetRecordSet(ctx, subscriptionId, rg, zoneName, relativeName, recordType)
+		if err == nil {
+			return fmt.Errorf("%s record set %s was created", recordType, relativeName)
+		}
+		if !tests.IsNotFound(err) {
+			return err
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return nil
+}
+
+// Waits numSeconds and returns an error if the private record set appears at any point
+func ensureNoPrivateRecordSet(ctx context.Context, rg, subscriptionId, zoneName, relativeName string, recordType armprivatedns.RecordType, numSeconds time.Duration) error {
+	lgr := logger.FromContext(ctx).With("zone", zoneName, "recordSet", relativeName, "recordType", recordType)
+	lgr.Info("ensuring record set is not created in Azure Private DNS")
+
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for time.Now().Before(timeout) {
+		_, err := tests.GetPrivateRecordSet(ctx, subscriptionId, rg, zoneName, relativeName, recordType)
+		if err == nil {
+			return fmt.Errorf("%s private record set %s was created", recordType, relativeName)
+		}
+		if !tests.IsNotFound(err) {
+			return err
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return nil
+}
+
+// Polls Azure DNS until the public record set is gone, returns an error if it still exists after numSeconds
+func waitForRecordSetDeleted(ctx context.Context, rg, subscriptionId, zoneName, relativeName string, recordType armdns.RecordType, numSeconds time.Duration) error {
+	lgr := logger.FromContext(ctx).With("zone", zoneName, "recordSet", relativeName, "recordType", recordType)
+	lgr.Info("waiting for record set to be deleted from Azure DNS")
+
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for {
+		_, err := tests.GetRecordSet(ctx, subscriptionId, rg, zoneName, relativeName, recordType)
+		if tests.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if time.Now().After(timeout) {
+			return fmt.Errorf("%s record set %s still exists after %d seconds", recordType, relativeName, numSeconds)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// Waits numSeconds and returns an error if the public record set disappears or stops passing check at any point
+func ensureRecordSetUnchanged(ctx context.Context, rg, subscriptionId, zoneName, relativeName string, recordType armdns.RecordType, numSeconds time.Duration, check func(*armdns.RecordSet) error) error {
+	lgr := logger.FromContext(ctx).With("zone", zoneName, "recordSet", relativeName, "recordType", recordType)
+	lgr.Info("ensuring record set is left unchanged in Azure DNS")
+
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for time.Now().Before(timeout) {
+		recordSet, err := tests.GetRecordSet(ctx, subscriptionId, rg, zoneName, relativeName, recordType)
+		if tests.IsNotFound(err) {
+			return fmt.Errorf("%s record set %s was deleted", recordType, relativeName)
+		}
+		if err != nil {
+			return err
+		}
+		if err := check(recordSet); err != nil {
+			return fmt.Errorf("%s record set %s was changed: %w", recordType, relativeName, err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return nil
+}
+
+// Returns the relative name of the ownership TXT record external dns writes for a record of recordType.
+// This is the newer registry format which prefixes the record type so it can coexist with CNAME records.
+func ownershipRecordName(relativeName string, recordType tests.IpFamily) string {
+	return strings.ToLower(string(recordType)) + "-" + relativeName
+}
+
+// Returns the value external dns writes into ownership TXT records for owner
+func ownershipValue(owner string) string {
+	return "heritage=external-dns,external-dns/owner=" + owner
+}
+
+// Returns a check that passes only when every check passes
+func allOf[T any](checks ...func(T) error) func(T) error {
+	return func(rs T) error {
+		for _, check := range checks {
+			if err := check(rs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Returns a check that passes when the public A record set contains ip
+func aRecordCheck(ip string) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, a := range rs.Properties.ARecords {
+			if a.IPv4Address != nil && *a.IPv4Address == ip {
+				return nil
+			}
+		}
+		return fmt.Errorf("A record for %s not found", ip)
+	}
+}
+
+// Returns a check that passes when the public A record set contains every ip in ips
+func aRecordsCheck(ips []string) func(*armdns.RecordSet) error {
+	checks := make([]func(*armdns.RecordSet) error, len(ips))
+	for i, ip := range ips {
+		checks[i] = aRecordCheck(ip)
+	}
+	return allOf(checks...)
+}
+
+// Returns a check that passes when the public record set has the expected TTL
+func ttlCheck(ttl int64) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil || rs.Properties.TTL == nil {
+			return fmt.Errorf("record set TTL is nil")
+		}
+		if *rs.Properties.TTL != ttl {
+			return fmt.Errorf("expected TTL %d, got %d", ttl, *rs.Properties.TTL)
+		}
+		return nil
+	}
+}
+
+// Returns a check that passes when the private A record set contains ip
+func privateARecordCheck(ip string) func(*armprivatedns.RecordSet) error {
+	return func(rs *armprivatedns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, a := range rs.Properties.ARecords {
+			if a.IPv4Address != nil && *a.IPv4Address == ip {
+				return nil
+			}
+		}
+		return fmt.Errorf("A record for %s not found", ip)
+	}
+}
+
+// Returns a check that passes when the private AAAA record set contains ip
+func privateAaaaRecordCheck(ip string) func(*armprivatedns.RecordSet) error {
+	return func(rs *armprivatedns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, aaaa := range rs.Properties.AaaaRecords {
+			if aaaa.IPv6Address != nil && net.ParseIP(*aaaa.IPv6Address).Equal(net.ParseIP(ip)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("AAAA record for %s not found", ip)
+	}
+}
+
+// Returns a check that passes when the private A record set contains every ip in ips
+func privateARecordsCheck(ips []string) func(*armprivatedns.RecordSet) error {
+	checks := make([]func(*armprivatedns.RecordSet) error, len(ips))
+	for i, ip := range ips {
+		checks[i] = privateARecordCheck(ip)
+	}
+	return allOf(checks...)
+}
+
+// Returns a check that passes when the private record set has the expected TTL
+func privateTTLCheck(ttl int64) func(*armprivatedns.RecordSet) error {
+	return func(rs *armprivatedns.RecordSet) error {
+		if rs.Properties == nil || rs.Properties.TTL == nil {
+			return fmt.Errorf("record set TTL is nil")
+		}
+		if *rs.Properties.TTL != ttl {
+			return fmt.Errorf("expected TTL %d, got %d", ttl, *rs.Properties.TTL)
+		}
+		return nil
+	}
+}
+
+// Returns a check that passes when the public CNAME record set points at target
+func cnameRecordCheck(target string) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil || rs.Properties.CnameRecord == nil || rs.Properties.CnameRecord.Cname == nil {
+			return fmt.Errorf("CNAME record is nil")
+		}
+		if cname := strings.TrimSuffix(*rs.Properties.CnameRecord.Cname, "."); cname != target {
+			return fmt.Errorf("expected CNAME %s, got %s", target, cname)
+		}
+		return nil
+	}
+}
+
+// Returns a check that passes when the private CNAME record set points at target
+func privateCnameRecordCheck(target string) func(*armprivatedns.RecordSet) error {
+	return func(rs *armprivatedns.RecordSet) error {
+		if rs.Properties == nil || rs.Properties.CnameRecord == nil || rs.Properties.CnameRecord.Cname == nil {
+			return fmt.Errorf("CNAME record is nil")
+		}
+		if cname := strings.TrimSuffix(*rs.Properties.CnameRecord.Cname, "."); cname != target {
+			return fmt.Errorf("expected CNAME %s, got %s", target, cname)
+		}
+		return nil
+	}
+}
+
+// Returns a check that passes when the public TXT record set marks owner as the owner of the record
+func ownershipCheck(owner string) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, txt := range rs.Properties.TxtRecords {
+			if strings.Contains(joinTxt(txt.Value), ownershipValue(owner)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("ownership TXT record for %s not found", owner)
+	}
+}
+
+// Returns a check that passes when the private TXT record set marks owner as the owner of the record
+func privateOwnershipCheck(owner string) func(*armprivatedns.RecordSet) error {
+	return func(rs *armprivatedns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, txt := range rs.Properties.TxtRecords {
+			if strings.Contains(joinTxt(txt.Value), ownershipValue(owner)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("ownership TXT record for %s not found", owner)
+	}
+}
+
+// Azure splits long TXT values into 255 character strings, joinTxt reassembles them
+func joinTxt(values []*string) string {
+	var b strings.Builder
+	for _, v := range values {
+		if v != nil {
+			b.WriteString(*v)
+		}
+	}
+	return b.String()
+}
+
+// Returns a check that passes when the public MX record set contains exchange at preference
+func mxRecordCheck(preference int32, exchange string) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, mx := range rs.Properties.MxRecords {
+			if mx.Preference != nil && *mx.Preference == preference && mx.Exchange != nil && strings.TrimSuffix(*mx.Exchange, ".") == exchange {
+				return nil
+			}
+		}
+		return fmt.Errorf("MX record %d %s not found", preference, exchange)
+	}
+}
+
+// Returns a check that passes when the private MX record set contains exchange at preference
+func privateMxRecordCheck(preference int32, exchange string) func(*armprivatedns.RecordSet) error {
+	return func(rs *armprivatedns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, mx := range rs.Properties.MxRecords {
+			if mx.Preference != nil && *mx.Preference == preference && mx.Exchange != nil && strings.TrimSuffix(*mx.Exchange, ".") == exchange {
+				return nil
+			}
+		}
+		return fmt.Errorf("MX record %d %s not found", preference, exchange)
+	}
+}
+
+// Returns a check that passes when the public TXT record set contains value
+func txtRecordCheck(value string) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, txt := range rs.Properties.TxtRecords {
+			if joinTxt(txt.Value) == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("TXT record %q not found", value)
+	}
+}
+
+// Returns a check that passes when the private TXT record set contains value
+func privateTxtRecordCheck(value string) func(*armprivatedns.RecordSet) error {
+	return func(rs *armprivatedns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, txt := range rs.Properties.TxtRecords {
+			if joinTxt(txt.Value) == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("TXT record %q not found", value)
+	}
+}
+
+// Returns a check that passes when the public SRV record set contains the given service location
+func srvRecordCheck(priority, weight, port int32, target string) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, srv := range rs.Properties.SrvRecords {
+			if srv.Priority != nil && *srv.Priority == priority && srv.Weight != nil && *srv.Weight == weight &&
+				srv.Port != nil && *srv.Port == port && srv.Target != nil && strings.TrimSuffix(*srv.Target, ".") == target {
+				return nil
+			}
+		}
+		return fmt.Errorf("SRV record %d %d %d %s not found", priority, weight, port, target)
+	}
+}
+
+// Returns a check that passes when the private SRV record set contains the given service location
+func privateSrvRecordCheck(priority, weight, port int32, target string) func(*armprivatedns.RecordSet) error {
+	return func(rs *armprivatedns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, srv := range rs.Properties.SrvRecords {
+			if srv.Priority != nil && *srv.Priority == priority && srv.Weight != nil && *srv.Weight == weight &&
+				srv.Port != nil && *srv.Port == port && srv.Target != nil && strings.TrimSuffix(*srv.Target, ".") == target {
+				return nil
+			}
+		}
+		return fmt.Errorf("SRV record %d %d %d %s not found", priority, weight, port, target)
+	}
+}
+
+// Returns a check that passes when the public NS record set delegates to nameserver
+func nsRecordCheck(nameserver string) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, ns := range rs.Properties.NsRecords {
+			if ns.Nsdname != nil && strings.TrimSuffix(*ns.Nsdname, ".") == nameserver {
+				return nil
+			}
+		}
+		return fmt.Errorf("NS record %s not found", nameserver)
+	}
+}
+
+// Returns a check that passes when the public CAA record set contains the given property
+func caaRecordCheck(flags int32, tag, value string) func(*armdns.RecordSet) error {
+	return func(rs *armdns.RecordSet) error {
+		if rs.Properties == nil {
+			return fmt.Errorf("record set properties are nil")
+		}
+		for _, caa := range rs.Properties.CaaRecords {
+			if caa.Flags != nil && *caa.Flags == flags && caa.Tag != nil && *caa.Tag == tag && caa.Value != nil && *caa.Value == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("CAA record %d %s %q not found", flags, tag, value)
+	}
+}
@@ -0,0 +1,76 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	centralServiceName = "nginx-svc-central"
+	centralName        = "app"
+)
+
+// Tests zones kept in a dns resource group apart from the cluster, which can also be in another subscription
+func resourceGroupsSuite(in infra.Provisioned) []test {
+	return []test{
+		{
+			name:     "public DNS + zone in dns resource group",
+			requires: []capability{publicDnsCapability, centralZoneCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := CentralZoneTest(ctx, in)
+				tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, centralServiceName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns dns resource group test finished successfully, deleting service ======== \n")
+				return nil
+			},
+		},
+	}
+}
+
+// Publishes a hostname in the zone in the dns resource group, which only the external dns instance for that group can write
+var CentralZoneTest = func(ctx context.Context, in infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + dns resource group test")
+
+	if tests.CentralZone == "" {
+		return fmt.Errorf("dns resource group was not provisioned for this infrastructure")
+	}
+
+	svc := clients.NewExternalNameService(centralServiceName, cnameTarget, clients.WithAnnotations(map[string]string{
+		tests.HostnameAnnotation: centralName + "." + tests.CentralZone,
+	}))
+	if err := in.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying service: %w", err)
+	}
+
+	txtName := ownershipRecordName(centralName, tests.Cname)
+	_, err := waitForRecordSet(ctx, tests.CentralZoneResourceGroup, tests.CentralZoneSubId, tests.CentralZone, centralName, armdns.RecordType(tests.Cname), recordTimeout, cnameRecordCheck(cnameTarget))
+	if err == nil {
+		_, err = waitForRecordSet(ctx, tests.CentralZoneResourceGroup, tests.CentralZoneSubId, tests.CentralZone, txtName, armdns.RecordTypeTXT, recordTimeout, ownershipCheck(in.Cluster.GetId()))
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.CentralZoneSubId, tests.CentralZoneResourceGroup, tests.CentralZone, centralName, armdns.RecordType(tests.Cname), ""); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting CNAME record set " + centralName)
+	}
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.CentralZoneSubId, tests.CentralZoneResourceGroup, tests.CentralZone, txtName, armdns.RecordTypeTXT, ""); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting TXT record set " + txtName)
+	}
+
+	if err != nil {
+		return fmt.Errorf("CNAME record %s not created in zone %s in resource group %s: %w", centralName, tests.CentralZone, tests.CentralZoneResourceGroup, err)
+	}
+
+	lgr.Info("Test Passed: Public dns + dns resource group")
+	return nil
+}
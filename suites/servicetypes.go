@@ -0,0 +1,327 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/clients"
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+const (
+	nginxSelector = "app=nginx"
+
+	headlessServiceName = "nginx-svc-headless"
+	nodePortServiceName = "nginx-svc-nodeport"
+	nodePort            = 30080
+)
+
+// headlessCase is a headless service published with an optional endpoints-type annotation
+type headlessCase struct {
+	name         string
+	relativeName string
+	// endpointsType is the endpoints-type annotation value, empty to publish pod ips
+	endpointsType string
+	// returns the ips external dns should publish for the nginx pods
+	ips func(pods []corev1.Pod) []string
+}
+
+var headlessCases = []headlessCase{
+	{
+		name:         "headless service",
+		relativeName: "headless",
+		ips: func(pods []corev1.Pod) []string {
+			var ips []string
+			for _, pod := range pods {
+				ips = append(ips, pod.Status.PodIP)
+			}
+			return ips
+		},
+	},
+	{
+		name:          "headless service + HostIP endpoints",
+		relativeName:  "headless-hostip",
+		endpointsType: "HostIP",
+		ips: func(pods []corev1.Pod) []string {
+			var ips []string
+			for _, pod := range pods {
+				ips = append(ips, pod.Status.HostIP)
+			}
+			return ips
+		},
+	},
+}
+
+// Returns the headless nginx service annotated with hostname for c
+func (c headlessCase) service(hostname string) *corev1.Service {
+	annotations := map[string]string{tests.HostnameAnnotation: hostname}
+	if c.endpointsType != "" {
+		annotations[tests.EndpointsTypeAnnotation] = c.endpointsType
+	}
+	return clients.NewNginxService(headlessServiceName, clients.HeadlessService, clients.WithAnnotations(annotations))
+}
+
+// Tests services that aren't load balancers: headless services publishing pod or host ips and
+// NodePort services publishing node ips and SRV records
+func serviceTypesSuite(in infra.Provisioned) []test {
+	var ret []test
+	for _, c := range headlessCases {
+		func(c headlessCase) {
+			ret = append(ret,
+				test{
+					name:     "public DNS + " + c.name,
+					requires: []capability{publicDnsCapability, ipv4PrimaryCapability},
+					run: func(ctx context.Context) error {
+						lgr := logger.FromContext(ctx)
+						err := HeadlessServiceTest(ctx, in, c)
+						tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, headlessServiceName)
+						if err != nil {
+							return err
+						}
+						lgr.Info("\n ======== Public Dns " + c.name + " test finished successfully, deleting service ======== \n")
+						return nil
+					},
+				},
+				test{
+					name:     "private DNS + " + c.name,
+					requires: []capability{linkedVnetCapability, ipv4PrimaryCapability},
+					run: func(ctx context.Context) error {
+						lgr := logger.FromContext(ctx)
+						err := PrivateHeadlessServiceTest(ctx, in, c)
+						tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, headlessServiceName)
+						if err != nil {
+							return err
+						}
+						lgr.Info("\n ======== Private Dns " + c.name + " test finished successfully, deleting service ======== \n")
+						return nil
+					},
+				},
+			)
+		}(c)
+	}
+
+	ret = append(ret,
+		test{
+			name:     "public DNS + NodePort service + public access",
+			requires: []capability{publicDnsCapability, ipv4PrimaryCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := NodePortServiceTest(ctx, in)
+				tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, nodePortServiceName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns NodePort test finished successfully, deleting service ======== \n")
+				return nil
+			},
+		},
+		test{
+			name:     "private DNS + NodePort service + private access",
+			requires: []capability{linkedVnetCapability, ipv4PrimaryCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := PrivateNodePortServiceTest(ctx, in)
+				tests.DeleteService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, nodePortServiceName)
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Private Dns NodePort test finished successfully, deleting service ======== \n")
+				return nil
+			},
+		},
+	)
+
+	return ret
+}
+
+var HeadlessServiceTest = func(ctx context.Context, infra infra.Provisioned, c headlessCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + " + c.name + " test")
+
+	if err := infra.Cluster.Deploy(ctx, []client.Object{c.service(c.relativeName + "." + tests.PublicZone)}); err != nil {
+		return fmt.Errorf("error deploying headless service: %w", err)
+	}
+
+	ips, err := nginxIps(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	_, err = waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, c.relativeName, armdns.RecordTypeA, recordTimeout, aRecordsCheck(ips))
+	if err != nil {
+		err = fmt.Errorf("%s A record set not created in Azure DNS: %w", c.name, err)
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, c.relativeName, armdns.RecordTypeA, ""); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting A record set " + c.relativeName)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + " + c.name)
+	return nil
+}
+
+var PrivateHeadlessServiceTest = func(ctx context.Context, infra infra.Provisioned, c headlessCase) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + " + c.name + " test")
+
+	if err := infra.Cluster.Deploy(ctx, []client.Object{c.service(c.relativeName + "." + tests.PrivateZone)}); err != nil {
+		return fmt.Errorf("error deploying headless service: %w", err)
+	}
+
+	ips, err := nginxIps(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	_, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, c.relativeName, armprivatedns.RecordTypeA, recordTimeout, privateARecordsCheck(ips))
+	if err != nil {
+		err = fmt.Errorf("%s A record set not created in Azure Private DNS: %w", c.name, err)
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, c.relativeName, "", armprivatedns.RecordTypeA); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting private A record set " + c.relativeName)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Private dns + " + c.name)
+	return nil
+}
+
+// NodePort services with public access publish node external ips. AKS nodes only have external ips when node
+// public ips are enabled, the test is skipped on clusters without them since there's nothing to publish
+var NodePortServiceTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + NodePort service test")
+
+	ips, err := tests.GetNodeAddresses(ctx, tests.SubId, tests.ResourceGroup, *tests.ClusterName, corev1.NodeExternalIP)
+	if err != nil {
+		return fmt.Errorf("getting node external ips: %w", err)
+	}
+	if len(ips) == 0 {
+		return tests.Skip("cluster nodes have no external ips, node public ips aren't enabled")
+	}
+
+	relativeName := "nodeport"
+	hostname := relativeName + "." + tests.PublicZone
+	svc := clients.NewNginxService(nodePortServiceName, clients.NodePortService, clients.WithNodePort(nodePort), clients.WithAnnotations(map[string]string{
+		tests.HostnameAnnotation: hostname,
+		tests.AccessAnnotation:   "public",
+	}))
+	if err := infra.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying NodePort service: %w", err)
+	}
+
+	_, err = waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, relativeName, armdns.RecordTypeA, recordTimeout, aRecordsCheck(ips))
+	if err != nil {
+		err = fmt.Errorf("NodePort A record set: %w", err)
+	}
+
+	srvName := nodePortSrvName(relativeName)
+	if err == nil {
+		if srvSupported {
+			_, err = waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, srvName, armdns.RecordTypeSRV, recordTimeout, srvRecordCheck(0, 50, nodePort, hostname))
+		} else {
+			err = ensureNoRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, srvName, armdns.RecordTypeSRV, recordTimeout)
+		}
+		if err != nil {
+			err = fmt.Errorf("NodePort SRV record set: %w", err)
+		}
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, relativeName, armdns.RecordTypeA, ""); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting A record set " + relativeName)
+	}
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, srvName, armdns.RecordTypeSRV, ""); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting SRV record set " + srvName)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Public dns + NodePort service")
+	return nil
+}
+
+// NodePort services with private access publish node internal ips, which only resolve inside the vnet
+var PrivateNodePortServiceTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + NodePort service test")
+
+	relativeName := "nodeport"
+	hostname := relativeName + "." + tests.PrivateZone
+	svc := clients.NewNginxService(nodePortServiceName, clients.NodePortService, clients.WithNodePort(nodePort), clients.WithAnnotations(map[string]string{
+		tests.HostnameAnnotation: hostname,
+		tests.AccessAnnotation:   "private",
+	}))
+	if err := infra.Cluster.Deploy(ctx, []client.Object{svc}); err != nil {
+		return fmt.Errorf("error deploying NodePort service: %w", err)
+	}
+
+	ips, err := tests.GetNodeAddresses(ctx, tests.SubId, tests.ResourceGroup, *tests.ClusterName, corev1.NodeInternalIP)
+	if err != nil {
+		return fmt.Errorf("getting node internal ips: %w", err)
+	}
+
+	_, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, relativeName, armprivatedns.RecordTypeA, recordTimeout, privateARecordsCheck(ips))
+	if err != nil {
+		err = fmt.Errorf("NodePort A record set not created in Azure Private DNS: %w", err)
+	}
+
+	srvName := nodePortSrvName(relativeName)
+	if err == nil {
+		if srvSupported {
+			_, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, srvName, armprivatedns.RecordTypeSRV, recordTimeout, privateSrvRecordCheck(0, 50, nodePort, hostname))
+		} else {
+			err = ensureNoPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, srvName, armprivatedns.RecordTypeSRV, recordTimeout)
+		}
+		if err != nil {
+			err = fmt.Errorf("NodePort SRV record set: %w", err)
+		}
+	}
+
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, relativeName, "", armprivatedns.RecordTypeA); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting private A record set " + relativeName)
+	}
+	if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, srvName, "", armprivatedns.RecordTypeSRV); delErr != nil && !tests.IsNotFound(delErr) {
+		lgr.Error("Error deleting private SRV record set " + srvName)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lgr.Info("Test Passed: Private dns + NodePort service")
+	return nil
+}
+
+// Returns the ips external dns should publish for the nginx pods behind the headless service in c
+func nginxIps(ctx context.Context, c headlessCase) ([]string, error) {
+	pods, err := tests.GetPods(ctx, tests.SubId, tests.ResourceGroup, *tests.ClusterName, nginxSelector)
+	if err != nil {
+		return nil, fmt.Errorf("getting nginx pods: %w", err)
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no running nginx pods")
+	}
+	return c.ips(pods), nil
+}
+
+// Returns the relative name of the SRV record external dns creates for the nginx NodePort service under relativeName
+func nodePortSrvName(relativeName string) string {
+	return "_" + nodePortServiceName + "._tcp." + relativeName
+}
@@ -0,0 +1,242 @@
+package suites
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+
+	"github.com/Azure/azure-provider-external-dns-e2e/infra"
+	"github.com/Azure/azure-provider-external-dns-e2e/logger"
+	"github.com/Azure/azure-provider-external-dns-e2e/tests"
+)
+
+// defaultTTL is the TTL the Azure providers use when no TTL annotation is set
+const defaultTTL int64 = 300
+
+type ttlCase struct {
+	name       string
+	annotation string // empty means the ttl annotation is not set
+	expected   int64
+}
+
+var ttlCases = []ttlCase{
+	{name: "default", annotation: "", expected: defaultTTL},
+	{name: "custom", annotation: "120", expected: 120},
+	{name: "duration", annotation: "1m", expected: 60},
+}
+
+// Tests that the ttl annotation is applied to records in the public and private dns zones
+func ttlSuite(in infra.Provisioned) []test {
+	return []test{
+		{
+			name:     "public DNS + TTL annotation",
+			requires: []capability{publicDnsCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				if err := PublicTTLTest(ctx, in); err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns TTL test finished successfully ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "private DNS + TTL annotation",
+			requires: []capability{linkedVnetCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				if err := PrivateTTLTest(ctx, in); err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Private Dns TTL test finished successfully ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "public DNS + TTL update",
+			requires: []capability{publicDnsCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := PublicTTLUpdateTest(ctx, in)
+				// the apex record is deleted however the test ended so its TTL doesn't leak into later tests
+				tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+				if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, "@", armdns.RecordTypeA, ""); delErr != nil && !tests.IsNotFound(delErr) {
+					lgr.Error("Error deleting A record set")
+				}
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Public Dns TTL update test finished successfully, clearing service annotations ======== \n")
+				return nil
+			},
+		},
+		{
+			name:     "private DNS + TTL update",
+			requires: []capability{linkedVnetCapability},
+			run: func(ctx context.Context) error {
+				lgr := logger.FromContext(ctx)
+				err := PrivateTTLUpdateTest(ctx, in)
+				// the apex record is deleted however the test ended so its TTL doesn't leak into later tests
+				tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+				if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, "@", "", armprivatedns.RecordTypeA); delErr != nil && !tests.IsNotFound(delErr) {
+					lgr.Error("Error deleting private A record set")
+				}
+				if err != nil {
+					return err
+				}
+				lgr.Info("\n ======== Private Dns TTL update test finished successfully, clearing service annotations ======== \n")
+				return nil
+			},
+		},
+	}
+}
+
+// Returns the annotations that publish the zone apex with the ttl of c
+func ttlAnnotations(zoneName string, c ttlCase) map[string]string {
+	annotationMap := map[string]string{
+		tests.HostnameAnnotation: zoneName,
+	}
+	if c.annotation != "" {
+		annotationMap[tests.TtlAnnotation] = c.annotation
+	}
+	return annotationMap
+}
+
+var PublicTTLTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + TTL test")
+
+	ip := tests.Ipv4Service.Status.LoadBalancer.Ingress[0].IP
+	for _, c := range ttlCases {
+		lgr.Info(fmt.Sprintf("checking %s TTL", c.name))
+
+		err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, ttlAnnotations(tests.PublicZone, c))
+		if err == nil {
+			_, err = waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, "@", armdns.RecordTypeA, recordTimeout, allOf(aRecordCheck(ip), ttlCheck(c.expected)))
+		}
+
+		tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PublicZone, "@", armdns.RecordTypeA, ""); delErr != nil {
+			lgr.Error("Error deleting A record set")
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s TTL not applied to public A record: %w", c.name, err)
+		}
+	}
+
+	lgr.Info("Test Passed: Public dns + TTL annotation")
+	return nil
+}
+
+var PrivateTTLTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + TTL test")
+
+	ip := tests.Ipv4Service.Status.LoadBalancer.Ingress[0].IP
+	for _, c := range ttlCases {
+		lgr.Info(fmt.Sprintf("checking %s TTL", c.name))
+
+		err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, ttlAnnotations(tests.PrivateZone, c))
+		if err == nil {
+			_, err = waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, "@", armprivatedns.RecordTypeA, recordTimeout, allOf(privateARecordCheck(ip), privateTTLCheck(c.expected)))
+		}
+
+		tests.ClearAnnotations(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, tests.Ipv4Service.Name)
+		if delErr := tests.DeleteRecordSet(ctx, *tests.ClusterName, tests.SubId, tests.ResourceGroup, tests.PrivateZone, "@", "", armprivatedns.RecordTypeA); delErr != nil {
+			lgr.Error("Error deleting private A record set")
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s TTL not applied to private A record: %w", c.name, err)
+		}
+	}
+
+	lgr.Info("Test Passed: Private dns + TTL annotation")
+	return nil
+}
+
+// Changes the ttl annotation on a published service and checks the existing record set is updated rather than recreated
+var PublicTTLUpdateTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting public dns + TTL update test")
+
+	ip := tests.Ipv4Service.Status.LoadBalancer.Ingress[0].IP
+	initial := ttlCase{name: "initial", annotation: "120", expected: 120}
+	updated := ttlCase{name: "updated", annotation: "600", expected: 600}
+
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, ttlAnnotations(tests.PublicZone, initial)); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+	if _, err := waitForRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PublicZone, "@", armdns.RecordTypeA, recordTimeout, allOf(aRecordCheck(ip), ttlCheck(initial.expected))); err != nil {
+		return fmt.Errorf("initial TTL not applied to public A record: %w", err)
+	}
+
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, ttlAnnotations(tests.PublicZone, updated)); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+
+	// the record set must never disappear while the TTL changes, otherwise it was recreated rather than updated
+	timeout := time.Now().Add(recordTimeout * time.Second)
+	for {
+		recordSet, err := tests.GetRecordSet(ctx, tests.SubId, tests.ResourceGroup, tests.PublicZone, "@", armdns.RecordTypeA)
+		if err != nil {
+			return fmt.Errorf("public A record set not present while updating TTL: %w", err)
+		}
+		err = allOf(aRecordCheck(ip), ttlCheck(updated.expected))(recordSet)
+		if err == nil {
+			break
+		}
+		if time.Now().After(timeout) {
+			return fmt.Errorf("updated TTL not applied to public A record: %w", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	lgr.Info("Test Passed: Public dns + TTL update")
+	return nil
+}
+
+// Changes the ttl annotation on a published service and checks the existing private record set is updated rather than recreated
+var PrivateTTLUpdateTest = func(ctx context.Context, infra infra.Provisioned) error {
+	lgr := logger.FromContext(ctx)
+	lgr.Info("starting private dns + TTL update test")
+
+	ip := tests.Ipv4Service.Status.LoadBalancer.Ingress[0].IP
+	initial := ttlCase{name: "initial", annotation: "120", expected: 120}
+	updated := ttlCase{name: "updated", annotation: "600", expected: 600}
+
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, ttlAnnotations(tests.PrivateZone, initial)); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+	if _, err := waitForPrivateRecordSet(ctx, tests.ResourceGroup, tests.SubId, tests.PrivateZone, "@", armprivatedns.RecordTypeA, recordTimeout, allOf(privateARecordCheck(ip), privateTTLCheck(initial.expected))); err != nil {
+		return fmt.Errorf("initial TTL not applied to private A record: %w", err)
+	}
+
+	if err := tests.AnnotateService(ctx, tests.SubId, *tests.ClusterName, tests.ResourceGroup, infra.Ipv4ServiceName, ttlAnnotations(tests.PrivateZone, updated)); err != nil {
+		return fmt.Errorf("error annotating service: %w", err)
+	}
+
+	// the record set must never disappear while the TTL changes, otherwise it was recreated rather than updated
+	timeout := time.Now().Add(recordTimeout * time.Second)
+	for {
+		recordSet, err := tests.GetPrivateRecordSet(ctx, tests.SubId, tests.ResourceGroup, tests.PrivateZone, "@", armprivatedns.RecordTypeA)
+		if err != nil {
+			return fmt.Errorf("private A record set not present while updating TTL: %w", err)
+		}
+		err = allOf(privateARecordCheck(ip), privateTTLCheck(updated.expected))(recordSet)
+		if err == nil {
+			break
+		}
+		if time.Now().After(timeout) {
+			return fmt.Errorf("updated TTL not applied to private A record: %w", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	lgr.Info("Test Passed: Private dns + TTL update")
+	return nil
+}
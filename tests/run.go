@@ -3,10 +3,11 @@ package tests
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -17,13 +18,24 @@ import (
 
 // global exported vars used by tests
 var (
-	ClusterName   *string
-	Ipv4Service   *corev1.Service
-	Ipv6Service   *corev1.Service
-	PublicZone    string
-	PrivateZone   string
-	ResourceGroup string
-	SubId         string
+	ClusterName *string
+	Ipv4Service *corev1.Service
+	Ipv6Service *corev1.Service
+	// load balancer services of the public and internal ingress controllers
+	IngressService         *corev1.Service
+	InternalIngressService *corev1.Service
+	PublicZone             string
+	// UnfilteredZone is a public zone outside the external dns domain filter
+	UnfilteredZone string
+	// NestedZone is a child zone of PublicZone in the same resource group
+	NestedZone string
+	// CentralZone is a public zone kept in a separate dns resource group, possibly in another subscription
+	CentralZone              string
+	CentralZoneResourceGroup string
+	CentralZoneSubId         string
+	PrivateZone              string
+	ResourceGroup            string
+	SubId                    string
 )
 
 func init() {
@@ -51,14 +63,46 @@ func SetObjectsForTesting(ctx context.Context, infra infra.Provisioned) error {
 	}
 	Ipv4Service = ipv4Svc
 
-	ipv6Svc, err := getServiceObj(ctx, infra.SubscriptionId, infra.ResourceGroup.GetName(), *ClusterName, infra.Ipv6ServiceName)
-	if err != nil {
-		lgr.Error("Error getting service object")
-		return fmt.Errorf("error getting service object")
+	Ipv6Service = nil
+	if infra.Ipv6ServiceName != "" {
+		Ipv6Service, err = getServiceObj(ctx, infra.SubscriptionId, infra.ResourceGroup.GetName(), *ClusterName, infra.Ipv6ServiceName)
+		if err != nil {
+			lgr.Error("Error getting service object")
+			return fmt.Errorf("error getting service object")
+		}
+	}
+
+	if infra.IngressServiceName != "" {
+		IngressService, err = getServiceObj(ctx, infra.SubscriptionId, infra.ResourceGroup.GetName(), *ClusterName, infra.IngressServiceName)
+		if err != nil {
+			lgr.Error("Error getting ingress controller service object")
+			return fmt.Errorf("error getting ingress controller service object")
+		}
+	}
+
+	if infra.InternalIngressServiceName != "" {
+		InternalIngressService, err = getServiceObj(ctx, infra.SubscriptionId, infra.ResourceGroup.GetName(), *ClusterName, infra.InternalIngressServiceName)
+		if err != nil {
+			lgr.Error("Error getting internal ingress controller service object")
+			return fmt.Errorf("error getting internal ingress controller service object")
+		}
 	}
-	Ipv6Service = ipv6Svc
 
 	for _, zone := range infra.Zones {
+		if zone.GetName() == infra.UnfilteredZoneName {
+			UnfilteredZone = zone.GetName()
+			continue
+		}
+		if zone.GetName() == infra.NestedZoneName {
+			NestedZone = zone.GetName()
+			continue
+		}
+		if zone.GetName() == infra.CentralZoneName {
+			CentralZone = zone.GetName()
+			CentralZoneResourceGroup = zone.GetResourceGroup()
+			CentralZoneSubId = zone.GetSubscriptionId()
+			continue
+		}
 		PublicZone = zone.GetName()
 	}
 
@@ -76,12 +120,17 @@ func (allTests Ts) Run(ctx context.Context, infra infra.Provisioned) error {
 	lgr := logger.FromContext(ctx)
 	lgr.Info("Starting to run all tests in suite")
 
-	runTestFn := func(t test, ctx context.Context) *logger.LoggedError {
+	runTestFn := func(t test, ctx context.Context) error {
 		lgr := logger.FromContext(ctx).With("test", t.GetName())
 		ctx = logger.WithContext(ctx, lgr)
 		lgr.Info("starting to run test")
 
 		if err := t.Run(ctx); err != nil {
+			// a skip isn't a failure, it's reported on its own so it isn't mistaken for a pass either
+			if errors.Is(err, ErrSkipped) {
+				lgr.Info("skipped test", "reason", err.Error())
+				return err
+			}
 			return logger.Error(lgr, err)
 		}
 
@@ -92,14 +141,26 @@ func (allTests Ts) Run(ctx context.Context, infra infra.Provisioned) error {
 	//Loop to run ALL Tests
 	lgr.Info("starting to run tests")
 
+	var passed, failed, skipped []string
 	for _, t := range allTests {
-		func(t test) error {
+		err := func(t test) error {
 			if err := runTestFn(t, ctx); err != nil {
 				return fmt.Errorf("running test: %w", err)
 			}
 			return nil
 		}(t)
+
+		switch {
+		case err == nil:
+			passed = append(passed, t.GetName())
+		case errors.Is(err, ErrSkipped):
+			skipped = append(skipped, t.GetName())
+		default:
+			failed = append(failed, t.GetName())
+		}
 	}
+
+	lgr.Info("finished running tests", "passed", len(passed), "failed", len(failed), "skipped", len(skipped), "failedTests", failed, "skippedTests", skipped)
 	return nil
 }
 
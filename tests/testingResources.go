@@ -6,14 +6,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerservice/armcontainerservice/v4"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 
 	"github.com/Azure/azure-provider-external-dns-e2e/clients"
 	"github.com/Azure/azure-provider-external-dns-e2e/logger"
@@ -28,8 +32,33 @@ const (
 	Cname IpFamily = "CNAME"
 	Mx    IpFamily = "MX"
 	Txt   IpFamily = "TXT"
+	Srv   IpFamily = "SRV"
+	Ns    IpFamily = "NS"
+	Caa   IpFamily = "CAA"
 )
 
+// annotations read by external dns
+const (
+	HostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+	TtlAnnotation      = "external-dns.alpha.kubernetes.io/ttl"
+	TargetAnnotation   = "external-dns.alpha.kubernetes.io/target"
+	// EndpointsTypeAnnotation picks the ips published for headless services, HostIP or NodeExternalIP instead of pod ips
+	EndpointsTypeAnnotation = "external-dns.alpha.kubernetes.io/endpoints-type"
+	// InternalHostnameAnnotation publishes the cluster ip of a service instead of its load balancer ip
+	InternalHostnameAnnotation = "external-dns.alpha.kubernetes.io/internal-hostname"
+	// AccessAnnotation picks the node ips published for NodePort services, public or private
+	AccessAnnotation = "external-dns.alpha.kubernetes.io/access"
+)
+
+// annotations read by the azure cloud provider
+const (
+	InternalLbAnnotation       = "service.beta.kubernetes.io/azure-load-balancer-internal"
+	InternalLbSubnetAnnotation = "service.beta.kubernetes.io/azure-load-balancer-internal-subnet"
+)
+
+// InternalRelativeName is where PrivateDnsAnnotations publishes the cluster ip of a service in the private zone
+const InternalRelativeName = "internal"
+
 var nonZeroExitCode = errors.New("non-zero exit code")
 
 type runCommandOpts struct {
@@ -45,7 +74,8 @@ func AnnotateService(ctx context.Context, subId, clusterName, rg, serviceName st
 	defer lgr.Info("finished annotating service")
 
 	for key, value := range annMap {
-		cmd := fmt.Sprintf("kubectl annotate service --overwrite %s %s=%s -n kube-system", serviceName, key, value)
+		// values are quoted so hostnames such as wildcards aren't expanded by the shell
+		cmd := fmt.Sprintf("kubectl annotate service --overwrite %s %s='%s' -n kube-system", serviceName, key, value)
 
 		if _, err := RunCommand(ctx, subId, rg, clusterName, armcontainerservice.RunCommandRequest{
 			Command: to.Ptr(cmd),
@@ -99,6 +129,161 @@ func ClearAnnotations(ctx context.Context, subId, clusterName, rg, serviceName s
 
 }
 
+// Deletes a service created by a test, does nothing if the service doesn't exist
+func DeleteService(ctx context.Context, subId, clusterName, rg, serviceName string) error {
+	return deleteObject(ctx, subId, clusterName, rg, "service", serviceName)
+}
+
+// Deletes an ingress created by a test, does nothing if the ingress doesn't exist
+func DeleteIngress(ctx context.Context, subId, clusterName, rg, ingressName string) error {
+	return deleteObject(ctx, subId, clusterName, rg, "ingress", ingressName)
+}
+
+// Deletes an HTTPRoute created by a test, does nothing if the route doesn't exist
+func DeleteHTTPRoute(ctx context.Context, subId, clusterName, rg, routeName string) error {
+	return deleteObject(ctx, subId, clusterName, rg, "httproute", routeName)
+}
+
+// Deletes a DNSEndpoint created by a test, does nothing if the endpoint doesn't exist
+func DeleteDNSEndpoint(ctx context.Context, subId, clusterName, rg, endpointName string) error {
+	return deleteObject(ctx, subId, clusterName, rg, "dnsendpoint", endpointName)
+}
+
+// Deletes a namespace created by a test along with everything in it, does nothing if the namespace doesn't exist
+func DeleteNamespace(ctx context.Context, subId, clusterName, rg, namespace string) error {
+	return deleteObject(ctx, subId, clusterName, rg, "namespace", namespace)
+}
+
+func deleteObject(ctx context.Context, subId, clusterName, rg, kind, name string) error {
+	lgr := logger.FromContext(ctx).With("name", clusterName, "resourceGroup", rg, "kind", kind, "object", name)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("starting to delete " + kind)
+	defer lgr.Info("finished deleting " + kind)
+
+	cmd := fmt.Sprintf("kubectl delete %s %s -n kube-system --ignore-not-found", kind, name)
+	if _, err := RunCommand(ctx, subId, rg, clusterName, armcontainerservice.RunCommandRequest{
+		Command: to.Ptr(cmd),
+	}, runCommandOpts{}); err != nil {
+		return fmt.Errorf("running kubectl delete: %w", err)
+	}
+
+	return nil
+}
+
+// Polls the gateway until the gateway implementation publishes an address to its status, which is what external dns reads for routes
+func WaitForGatewayAddress(ctx context.Context, numSeconds time.Duration, subId, rg, clusterName, gatewayName string) (string, error) {
+	lgr := logger.FromContext(ctx).With("name", clusterName, "resourceGroup", rg, "gateway", gatewayName)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("waiting for gateway address")
+	defer lgr.Info("finished waiting for gateway address")
+
+	cmd := fmt.Sprintf("kubectl get gateway %s -n kube-system -o json", gatewayName)
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for {
+		result, err := RunCommand(ctx, subId, rg, clusterName, armcontainerservice.RunCommandRequest{
+			Command: to.Ptr(cmd),
+		}, runCommandOpts{})
+		if err != nil {
+			return "", fmt.Errorf("getting gateway %s: %w", gatewayName, err)
+		}
+
+		gateway := &gatewayv1.Gateway{}
+		if err := json.Unmarshal([]byte(*result.Logs), gateway); err != nil {
+			return "", fmt.Errorf("unmarshaling json for gateway: %w", err)
+		}
+		if len(gateway.Status.Addresses) > 0 && gateway.Status.Addresses[0].Value != "" {
+			return gateway.Status.Addresses[0].Value, nil
+		}
+
+		if time.Now().After(timeout) {
+			return "", fmt.Errorf("gateway %s has no address after %d seconds", gatewayName, numSeconds)
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// Returns the running pods in kube-system matching the label selector
+func GetPods(ctx context.Context, subId, rg, clusterName, selector string) ([]corev1.Pod, error) {
+	lgr := logger.FromContext(ctx).With("name", clusterName, "resourceGroup", rg, "selector", selector)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("getting pods")
+	defer lgr.Info("finished getting pods")
+
+	cmd := fmt.Sprintf("kubectl get pods -l %s -n kube-system --field-selector=status.phase=Running -o json", selector)
+	result, err := RunCommand(ctx, subId, rg, clusterName, armcontainerservice.RunCommandRequest{
+		Command: to.Ptr(cmd),
+	}, runCommandOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("getting pods for %s: %w", selector, err)
+	}
+
+	pods := &corev1.PodList{}
+	if err := json.Unmarshal([]byte(*result.Logs), pods); err != nil {
+		return nil, fmt.Errorf("unmarshaling json for pods: %w", err)
+	}
+
+	return pods.Items, nil
+}
+
+// Polls the logs of the pods in kube-system matching the label selector until they contain substr,
+// returns an error if they don't after numSeconds
+func WaitForPodLog(ctx context.Context, numSeconds time.Duration, subId, rg, clusterName, selector, substr string) error {
+	lgr := logger.FromContext(ctx).With("name", clusterName, "resourceGroup", rg, "selector", selector, "substr", substr)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("waiting for pod logs")
+	defer lgr.Info("finished waiting for pod logs")
+
+	cmd := fmt.Sprintf("kubectl logs -l %s -n kube-system --tail=-1", selector)
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for {
+		result, err := RunCommand(ctx, subId, rg, clusterName, armcontainerservice.RunCommandRequest{
+			Command: to.Ptr(cmd),
+		}, runCommandOpts{})
+		if err != nil {
+			return fmt.Errorf("getting logs for %s: %w", selector, err)
+		}
+		if result.Logs != nil && strings.Contains(*result.Logs, substr) {
+			return nil
+		}
+
+		if time.Now().After(timeout) {
+			return fmt.Errorf("logs for %s don't contain %q after %d seconds", selector, substr, numSeconds)
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// Returns every address of addressType across the nodes of the cluster
+func GetNodeAddresses(ctx context.Context, subId, rg, clusterName string, addressType corev1.NodeAddressType) ([]string, error) {
+	lgr := logger.FromContext(ctx).With("name", clusterName, "resourceGroup", rg, "addressType", addressType)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("getting node addresses")
+	defer lgr.Info("finished getting node addresses")
+
+	result, err := RunCommand(ctx, subId, rg, clusterName, armcontainerservice.RunCommandRequest{
+		Command: to.Ptr("kubectl get nodes -o json"),
+	}, runCommandOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("getting nodes: %w", err)
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := json.Unmarshal([]byte(*result.Logs), nodes); err != nil {
+		return nil, fmt.Errorf("unmarshaling json for nodes: %w", err)
+	}
+
+	var addresses []string
+	for _, node := range nodes.Items {
+		for _, address := range node.Status.Addresses {
+			if address.Type == addressType {
+				addresses = append(addresses, address.Address)
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
 // Checks to see that external dns pod is running
 func WaitForExternalDns(ctx context.Context, numSeconds time.Duration, subId, rg, clusterName, provider string) error {
 	lgr := logger.FromContext(ctx).With("name", clusterName, "resourceGroup", rg)
@@ -150,15 +335,20 @@ func WaitForExternalDns(ctx context.Context, numSeconds time.Duration, subId, rg
 
 }
 
-// Adds annotations needed specifically for private dns tests
-func PrivateDnsAnnotations(ctx context.Context, subId, clusterName, rg, serviceName string) error {
+// Adds annotations needed specifically for private dns tests. The service is moved behind an internal load balancer,
+// in internalSubnet when it isn't empty, and published at the private zone apex. Its cluster ip is published at
+// InternalRelativeName in the private zone through the internal-hostname annotation
+func PrivateDnsAnnotations(ctx context.Context, subId, clusterName, rg, serviceName, internalSubnet string) error {
 	lgr := logger.FromContext(ctx)
 	lgr.Info("Adding annotations for private dns")
 
 	annotationMap := map[string]string{
-		"external-dns.alpha.kubernetes.io/hostname":               PrivateZone,
-		"service.beta.kubernetes.io/azure-load-balancer-internal": "true",
-		"external-dns.alpha.kubernetes.io/internal-hostname":      "server-clusterip.example.com",
+		HostnameAnnotation:         PrivateZone,
+		InternalLbAnnotation:       "true",
+		InternalHostnameAnnotation: InternalRelativeName + "." + PrivateZone,
+	}
+	if internalSubnet != "" {
+		annotationMap[InternalLbSubnetAnnotation] = internalSubnet
 	}
 	err := AnnotateService(ctx, subId, clusterName, rg, serviceName, annotationMap)
 	if err != nil {
@@ -170,6 +360,33 @@ func PrivateDnsAnnotations(ctx context.Context, subId, clusterName, rg, serviceN
 
 }
 
+// Returns the service once the first ip of its load balancer passes accept.
+// Azure replaces the load balancer ip whenever a service moves between a public and an internal load balancer
+func WaitForLoadBalancerIp(ctx context.Context, numSeconds time.Duration, subId, rg, clusterName, serviceName string, accept func(ip net.IP) bool) (*corev1.Service, error) {
+	lgr := logger.FromContext(ctx).With("name", clusterName, "resourceGroup", rg, "service", serviceName)
+	ctx = logger.WithContext(ctx, lgr)
+	lgr.Info("waiting for load balancer ip")
+	defer lgr.Info("finished waiting for load balancer ip")
+
+	timeout := time.Now().Add(numSeconds * time.Second)
+	for {
+		svc, err := getServiceObj(ctx, subId, rg, clusterName, serviceName)
+		if err != nil {
+			return nil, err
+		}
+		if ingress := svc.Status.LoadBalancer.Ingress; len(ingress) > 0 {
+			if ip := net.ParseIP(ingress[0].IP); ip != nil && accept(ip) {
+				return svc, nil
+			}
+		}
+
+		if time.Now().After(timeout) {
+			return nil, fmt.Errorf("service %s has no matching load balancer ip after %d seconds", serviceName, numSeconds)
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
 func RunCommand(ctx context.Context, subId, rg, clusterName string, request armcontainerservice.RunCommandRequest, opt runCommandOpts) (armcontainerservice.CommandResultProperties, error) {
 	lgr := logger.FromContext(ctx)
 	ctx = logger.WithContext(ctx, lgr)
@@ -227,10 +444,10 @@ func RunCommand(ctx context.Context, subId, rg, clusterName string, request armc
 	return *result.Properties, nil
 }
 
-// Deletes a record set in a public dns zone or private dns zone in Azure DNS
+// Deletes a record set in a public dns zone or private dns zone in Azure DNS, relativeName is "@" for the zone apex
 // Called after each test to allow subsequent test to run properly
-func DeleteRecordSet(ctx context.Context, clusterName, subId, rg, zoneName string, recordType armdns.RecordType, privateRecordType armprivatedns.RecordType) error {
-	lgr := logger.FromContext(ctx)
+func DeleteRecordSet(ctx context.Context, clusterName, subId, rg, zoneName, relativeName string, recordType armdns.RecordType, privateRecordType armprivatedns.RecordType) error {
+	lgr := logger.FromContext(ctx).With("zone", zoneName, "recordSet", relativeName)
 
 	lgr.Info("Starting to delete record set")
 	defer lgr.Info("finished deleting record set")
@@ -247,7 +464,7 @@ func DeleteRecordSet(ctx context.Context, clusterName, subId, rg, zoneName strin
 			lgr.Error("failed to create client ", err)
 			return err
 		}
-		_, err = clientFactory.NewRecordSetsClient().Delete(ctx, rg, zoneName, "@", recordType, &armdns.RecordSetsClientDeleteOptions{IfMatch: nil})
+		_, err = clientFactory.NewRecordSetsClient().Delete(ctx, rg, zoneName, relativeName, recordType, &armdns.RecordSetsClientDeleteOptions{IfMatch: nil})
 		if err != nil {
 			lgr.Error("failed to delete record set in public dns zone ", err)
 			return err
@@ -258,7 +475,7 @@ func DeleteRecordSet(ctx context.Context, clusterName, subId, rg, zoneName strin
 			lgr.Error("failed to create client", err)
 			return err
 		}
-		_, err = privateClientFactory.NewRecordSetsClient().Delete(ctx, rg, zoneName, privateRecordType, "@", &armprivatedns.RecordSetsClientDeleteOptions{IfMatch: nil})
+		_, err = privateClientFactory.NewRecordSetsClient().Delete(ctx, rg, zoneName, privateRecordType, relativeName, &armprivatedns.RecordSetsClientDeleteOptions{IfMatch: nil})
 		if err != nil {
 			lgr.Error("failed to delete record set in private dns zone ", err)
 			return err
@@ -267,3 +484,48 @@ func DeleteRecordSet(ctx context.Context, clusterName, subId, rg, zoneName strin
 	}
 	return nil
 }
+
+// Retrieves a record set from a public dns zone in Azure DNS, relativeName is "@" for the zone apex
+func GetRecordSet(ctx context.Context, subId, rg, zoneName, relativeName string, recordType armdns.RecordType) (*armdns.RecordSet, error) {
+	cred, err := clients.GetAzCred()
+	if err != nil {
+		return nil, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	clientFactory, err := armdns.NewClientFactory(subId, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating client factory: %w", err)
+	}
+
+	resp, err := clientFactory.NewRecordSetsClient().Get(ctx, rg, zoneName, relativeName, recordType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s record set %s in zone %s: %w", recordType, relativeName, zoneName, err)
+	}
+
+	return &resp.RecordSet, nil
+}
+
+// Retrieves a record set from a private dns zone in Azure DNS, relativeName is "@" for the zone apex
+func GetPrivateRecordSet(ctx context.Context, subId, rg, zoneName, relativeName string, recordType armprivatedns.RecordType) (*armprivatedns.RecordSet, error) {
+	cred, err := clients.GetAzCred()
+	if err != nil {
+		return nil, fmt.Errorf("getting az credentials: %w", err)
+	}
+
+	clientFactory, err := armprivatedns.NewClientFactory(subId, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating client factory: %w", err)
+	}
+
+	resp, err := clientFactory.NewRecordSetsClient().Get(ctx, rg, zoneName, recordType, relativeName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s private record set %s in zone %s: %w", recordType, relativeName, zoneName, err)
+	}
+
+	return &resp.RecordSet, nil
+}
+
+// Returns true if err was caused by Azure responding that the resource does not exist
+func IsNotFound(err error) bool {
+	return clients.IsNotFound(err)
+}
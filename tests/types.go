@@ -2,8 +2,19 @@ package tests
 
 import (
 	"context"
+	"errors"
+	"fmt"
 )
 
+// ErrSkipped is returned by a test that didn't run, wrapped with the reason. Ts.Run reports these as skipped rather than
+// passed or failed
+var ErrSkipped = errors.New("test skipped")
+
+// Skip returns the error a test returns when it can't run against the infrastructure, reason says why
+func Skip(reason string) error {
+	return fmt.Errorf("%w: %s", ErrSkipped, reason)
+}
+
 type test interface {
 	GetName() string
 	Run(ctx context.Context) error